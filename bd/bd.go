@@ -1,16 +1,37 @@
 package bd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"strings"
+	"time"
+	"vacancydealer/textnorm"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
-var DB DBentity
+var (
+	DB DBentity
+
+	// колонки job_announces, которые заполняет выгрузка (без подробностей)
+	harvestedColumns = []string{"source", "source_id", "name", "name_norm", "company", "employer_trusted", "area", "expierence", "salary_gross", "salary_from", "salary_to", "salary_currency", "published_at", "schedule", "requirement", "responsebility", "link", "fingerprint", "canonical_id"}
+)
+
+const (
+	jobAnnouncesBatchSize = 500
+	foreignItemIDBase     = 1 << 40
+
+	detailsMaxAttempts = 3
+
+	// формат JobAnnounce.PublishedAt, общий для всех источников. Дата хранится в UTC,
+	// поэтому строки сравниваются и сортируются в порядке времени
+	PublishedAtLayout = "2006-01-02T15:04:05-0700"
+)
 
 // ---------------------------------------->>>INITIALIZATION---------------------------------------------------------------------
 func Init(host, user, password, dbname string, port int, sslmode string) (err error) {
@@ -24,8 +45,68 @@ func Init(host, user, password, dbname string, port int, sslmode string) (err er
 }
 
 func Migrate() (err error) {
-	if err = DB.Socket.AutoMigrate(UserData{}, JobAnnounce{}, UserPivotVacancy{}, CountrySQL{}, Region{}, City{}, Schedule{}, VacancynameSearchPattern{}); err != nil {
+	// отметки выгрузки до появления источников были без ключа source - проще выгрузить заново
+	if DB.Socket.Migrator().HasTable(&HarvestWatermark{}) && !DB.Socket.Migrator().HasColumn(&HarvestWatermark{}, "source") {
+		if err = DB.Socket.Migrator().DropTable(&HarvestWatermark{}); err != nil {
+			err = fmt.Errorf("database harvest watermarks drop error: %w", err)
+			return
+		}
+	}
+
+	if err = migrateDeliveriesIndex(); err != nil {
+		return
+	}
+
+	if err = DB.Socket.AutoMigrate(UserData{}, JobAnnounce{}, UserPivotVacancy{}, CountrySQL{}, Region{}, City{}, Schedule{}, VacancynameSearchPattern{}, HarvestWatermark{}, SourceArea{}, Catalogue{}, JobFeed{}, Subscription{}, Currency{}, BlockedEmployer{}, UserState{}, SavedVacancy{}); err != nil {
 		err = fmt.Errorf("database automigration error: %w", err)
+		return
+	}
+
+	if err = migrateNullDefaults(); err != nil {
+		return
+	}
+	if err = migrateUserFiltersToSubscriptions(); err != nil {
+		return
+	}
+	if err = migrateDeliveriesSentAt(); err != nil {
+		return
+	}
+	if err = migrateNameNorm(); err != nil {
+		return
+	}
+	if err = migratePublishedAtUTC(); err != nil {
+		return
+	}
+	return migrateSearchVector()
+}
+
+// Колонки, добавленные AutoMigrate к существующим записям, у старых записей NULL,
+// а условия вида "onboarding_step = 0" на NULL ложны. Значение по умолчанию проставляется явно
+func migrateNullDefaults() (err error) {
+	for _, column := range []struct {
+		model any
+		name  string
+	}{
+		{&UserData{}, "onboarding_step"},
+		{&JobAnnounce{}, "details_attempts"},
+		{&JobAnnounce{}, "canonical_id"},
+	} {
+		if err = DB.Socket.Model(column.model).Where(column.name+" is null").Update(column.name, 0).Error; err != nil {
+			return fmt.Errorf("%s null backfill error: %w", column.name, err)
+		}
+	}
+	return nil
+}
+
+// Закрытие пула соединений
+func Close() (err error) {
+	sqlDB, err := DB.Socket.DB()
+	if err != nil {
+		err = fmt.Errorf("database pool getting error: %w", err)
+		return
+	}
+	if err = sqlDB.Close(); err != nil {
+		err = fmt.Errorf("database pool closing error: %w", err)
 	}
 	return
 }
@@ -54,6 +135,16 @@ func (cities SQLcities) WriteToDB() (err error) {
 	return
 }
 
+func (areas SourceAreas) SaveInDB() (err error) {
+	if len(areas) == 0 {
+		return nil
+	}
+	if err = DB.Socket.Save(&areas).Error; err != nil {
+		err = fmt.Errorf("source areas mapping save error: %w", err)
+	}
+	return
+}
+
 // -------------------------------------------------------------<<<LOCATION WRITERS-----------------------------------------------------
 // S--U--
 func FindOrCreateUser(tgID int64) (u UserData, err error) {
@@ -61,9 +152,12 @@ func FindOrCreateUser(tgID int64) (u UserData, err error) {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			u.TgID = tgID
 			u.Schedule = "fullDay"
+			u.OnboardingStep = 1 // новый пользователь начинает со знакомства с ботом
 			if err = DB.Socket.Create(&u).Error; err != nil {
 				err = fmt.Errorf("user creating error: %w", err)
+				return
 			}
+			_, err = CreateSubscription(tgID, DefaultSubscriptionName)
 			return
 		} else {
 			err = fmt.Errorf("user finding error: %w", err)
@@ -73,48 +167,6 @@ func FindOrCreateUser(tgID int64) (u UserData, err error) {
 	return
 }
 
-// User Data Update
-func (u UserData) Update() (err error) {
-	sqlu, err := FindOrCreateUser(u.TgID)
-	if err != nil {
-		err = fmt.Errorf("update user data error: %w", err)
-		return
-	}
-	sqlu.ExperienceYear = u.ExperienceYear
-	sqlu.Schedule = u.Schedule
-	sqlu.VacancyName = u.VacancyName
-	if err = DB.Socket.Model(&sqlu).Select("vacancy_name", "experience_year").Updates(UserData{VacancyName: sqlu.VacancyName, ExperienceYear: sqlu.ExperienceYear}).Error; err != nil {
-		err = fmt.Errorf("updating userData error: %w", err)
-		return
-	}
-
-	WorkDue <- true
-
-	return nil
-}
-
-func (u UserData) UpdateLocation() (err error) {
-	if err = DB.Socket.Model(&u).Where("tg_id=?", u.TgID).Update("location", u.Location).Error; err != nil {
-		err = fmt.Errorf("user data location on db update error: %w", err)
-		return
-	}
-
-	WorkDue <- true
-
-	return nil
-}
-
-func (u UserData) UpdateSchedule() (err error) {
-	if err = DB.Socket.Model(&u).Where("tg_id=?", u.TgID).Update("schedule", u.Schedule).Error; err != nil {
-		err = fmt.Errorf("user data schedule field in db update error:%w", err)
-		return
-	}
-
-	WorkDue <- true
-
-	return nil
-}
-
 func (areas SQLcountries) IdsSequence() (iDs []uint) {
 	iDs = make([]uint, 0, len(areas))
 	for _, area := range areas {
@@ -346,6 +398,33 @@ func (ad Countries) FindContainLocationIDsList(areaID uint) (locationListIDs []u
 	return
 }
 
+// Непосредственно вложенные локации: для 0 - страны, для страны - регионы, для региона - города
+func (ad Countries) FindChildLocationIDs(areaID uint) (locationListIDs []uint) {
+	for _, country := range ad {
+		if areaID == 0 {
+			locationListIDs = append(locationListIDs, country.Count.ID)
+			continue
+		}
+
+		if country.Count.ID == areaID {
+			for _, region := range country.Regions {
+				locationListIDs = append(locationListIDs, region.Region.ID)
+			}
+			return
+		}
+
+		for _, region := range country.Regions {
+			if region.Region.ID == areaID {
+				for _, city := range region.Cities {
+					locationListIDs = append(locationListIDs, city.ID)
+				}
+				return
+			}
+		}
+	}
+	return
+}
+
 // Поиск локации по ИД
 // Проверяет ИД по порядку в таблицах: стран, регионов, населенных пунктов
 func FindLocByID(locID uint) (locName string, err error) {
@@ -393,6 +472,14 @@ func (sch Schedules) CreateToDB() (err error) {
 	return
 }
 
+// Дописывает отсутствующие графики, не трогая уже загруженные
+func (sch Schedules) CreateMissingToDB() (err error) {
+	if err = DB.Socket.Clauses(clause.OnConflict{DoNothing: true}).Create(&sch).Error; err != nil {
+		err = fmt.Errorf("missing schedules create error: %w", err)
+	}
+	return
+}
+
 // shedules finding
 func GetSchedule(scheduleID string) (schdules Schedules, err error) {
 	if len(scheduleID) == 0 {
@@ -414,95 +501,190 @@ func GetSchedulesList() (schedules Schedules, err error) {
 	return
 }
 
+func (catalogues Catalogues) SaveInDB() (err error) {
+	if len(catalogues) == 0 {
+		return nil
+	}
+	if err = DB.Socket.Save(&catalogues).Error; err != nil {
+		err = fmt.Errorf("source catalogues save error: %w", err)
+	}
+	return
+}
+
+// Ленты из конфига: новые добавляются, уже известные (в т.ч. отключенные админом) не трогаются
+func (feeds JobFeeds) SeedToDB() (err error) {
+	if len(feeds) == 0 {
+		return nil
+	}
+	if err = DB.Socket.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "url"}}, DoNothing: true}).Create(&feeds).Error; err != nil {
+		err = fmt.Errorf("job feeds seeding error: %w", err)
+	}
+	return
+}
+
+func GetEnabledFeeds(ctx context.Context) (feeds JobFeeds, err error) {
+	if err = DB.Socket.WithContext(ctx).Where("enabled = ?", true).Find(&feeds).Error; err != nil {
+		err = fmt.Errorf("enabled job feeds getting error: %w", err)
+	}
+	return
+}
+
+func (feed JobFeed) UpdatePolled(polledAt time.Time) (err error) {
+	if err = DB.Socket.Model(&feed).Update("last_polled_at", polledAt).Error; err != nil {
+		err = fmt.Errorf("job feed %d polled time update error: %w", feed.ID, err)
+	}
+	return
+}
+
 // Pool of vacancie search keys from DB getting
-func GetVacancyPatterns() (vacNames VacancyNamePatterns, err error) {
-	if err = DB.Socket.Find(&vacNames).Error; err != nil {
+func GetVacancyPatterns(ctx context.Context) (vacNames VacancyNamePatterns, err error) {
+	if err = DB.Socket.WithContext(ctx).Find(&vacNames).Error; err != nil {
 		err = fmt.Errorf("vacancie name poll getting error: %w", err)
 		return nil, err
 	}
 	return vacNames, nil
 }
 
-// -------------------------------------------------------<<<JobData-----------------------
-func (ja JobAnnounces) SaveInDB() (err error) {
-	if err = DB.Socket.Save(&ja).Error; err != nil {
-		err = fmt.Errorf("job announces update error: %w", err)
+// Отметка выгрузки шаблона из источника. Для нового шаблона возвращается пустая отметка
+func GetHarvestWatermark(ctx context.Context, source, pattern string) (mark HarvestWatermark, err error) {
+	if err = DB.Socket.WithContext(ctx).Where("source=? and pattern=?", source, pattern).First(&mark).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return HarvestWatermark{Source: source, Pattern: pattern}, nil
+		}
+		err = fmt.Errorf("harvest watermark getting error: %w", err)
 	}
 	return
 }
 
-func (ud UserData) GetJobAnnounces(areas Countries) (announces JobAnnounces, err error) {
-	var expierence string
-	if ud.ExperienceYear < 1 {
-		expierence = "noExperience"
-	} else if ud.ExperienceYear >= 1 && ud.ExperienceYear <= 3 {
-		expierence = "between1And3"
-	} else if ud.ExperienceYear < 3 && ud.ExperienceYear <= 6 {
-		expierence = "between3And6"
-	} else if ud.ExperienceYear > 6 {
-		expierence = "moreThan6"
+func (mark HarvestWatermark) SaveInDB() (err error) {
+	if err = DB.Socket.Save(&mark).Error; err != nil {
+		err = fmt.Errorf("harvest watermark saving error: %w", err)
 	}
+	return
+}
 
-	var shownAnnounces []UserPivotVacancy
-	if err = DB.Socket.Where("uid=?", ud.TgID).Find(&shownAnnounces).Error; err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			err = fmt.Errorf("shown announces getting error: %w", err)
-			return
-		}
+// -------------------------------------------------------<<<JobData-----------------------
+// Запись пачками: полная выгрузка шаблона может превышать лимит параметров одного запроса
+func (ja JobAnnounces) SaveInDB(ctx context.Context) (err error) {
+	if len(ja) == 0 {
+		return nil
+	}
+	for i := range ja {
+		ja[i].NameNorm = textnorm.Normalize(ja[i].Name)
+	}
+	if err = ja.fillFirstSeen(ctx); err != nil {
+		return
+	}
+	for i := range ja {
+		ja[i].PublishedAt = publishedAtUTC(ja[i].PublishedAt)
+	}
+	// подробности пишет только очередь подробностей, повторная выгрузка их не затирает
+	onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "item_id"}}, DoUpdates: clause.AssignmentColumns(harvestedColumns)}
+	if err = DB.Socket.WithContext(ctx).Clauses(onConflict).CreateInBatches(&ja, jobAnnouncesBatchSize).Error; err != nil {
+		err = fmt.Errorf("job announces update error: %w", err)
+	}
+	return
+}
 
+// Очередь подробностей: новые вакансии указанных источников без загруженной карточки, свежие по дате публикации первыми.
+// ИД вакансий других источников начинаются с foreignItemIDBase - порядок по ИД ставил бы их впереди всех вакансий ХэХа
+func GetDetailsQueue(ctx context.Context, sources []string, limit int) (queue JobAnnounces, err error) {
+	if err = DB.Socket.WithContext(ctx).Where("details_fetched_at is null and details_attempts < ? and source in ?", detailsMaxAttempts, sources).Order("published_at desc, item_id desc").Limit(limit).Find(&queue).Error; err != nil {
+		err = fmt.Errorf("vacancy details queue getting error: %w", err)
 	}
+	return
+}
 
-	var shownAnnouncesIDs []uint
-	for _, shown := range shownAnnounces {
-		shownAnnouncesIDs = append(shownAnnouncesIDs, shown.JobID)
+// Запись подробностей вакансии
+func (a JobAnnounce) SaveDetails() (err error) {
+	fetchedAt := time.Now()
+	a.DetailsFetchedAt = &fetchedAt
+	if err = DB.Socket.Model(&JobAnnounce{ItemId: a.ItemId}).Select("description", "key_skills", "employment", "address", "has_contacts", "professional_roles", "archived", "details_fetched_at").Updates(a).Error; err != nil {
+		err = fmt.Errorf("vacancy %d details saving error: %w", a.ItemId, err)
 	}
+	return
+}
 
-	locationsTarget := areas.FindContainLocationIDsList(ud.Location)
-	if len(locationsTarget) == 0 {
-		if len(shownAnnounces) != 0 {
-			if err = DB.Socket.Limit(50).Where("LOWER(name) like ? and expierence = ? and schedule = ? and  item_id not in ? ", "%"+strings.ToLower(ud.VacancyName)+"%", expierence, ud.Schedule, shownAnnouncesIDs).Find(&announces).Error; err != nil {
-				err = fmt.Errorf("db vacancy with param schedule getting error: %w", err)
-				return
-			}
+// Неудачная попытка загрузки подробностей: после detailsMaxAttempts вакансия выпадает из очереди
+func (a JobAnnounce) DetailsAttemptFailed() (err error) {
+	if err = DB.Socket.Model(&JobAnnounce{ItemId: a.ItemId}).Update("details_attempts", gorm.Expr("details_attempts + 1")).Error; err != nil {
+		err = fmt.Errorf("vacancy %d details attempts update error: %w", a.ItemId, err)
+	}
+	return
+}
 
-		} else {
+// Дата публикации в UTC. Нераспознанная дата остается как есть
+func publishedAtUTC(published string) string {
+	t, err := time.Parse(PublishedAtLayout, published)
+	if err != nil {
+		return published
+	}
+	return t.UTC().Format(PublishedAtLayout)
+}
 
-			if err = DB.Socket.Limit(50).Where("LOWER(name) like ? and expierence = ? and schedule = ?", "%"+strings.ToLower(ud.VacancyName)+"%", expierence, ud.Schedule).Find(&announces).Error; err != nil {
-				err = fmt.Errorf("db vacancy with param schedule getting error: %w", err)
-				return
-			}
+// Даты публикации, записанные до хранения в UTC, - с часовым поясом источника
+func migratePublishedAtUTC() (err error) {
+	if err = DB.Socket.Exec(`UPDATE job_announces SET published_at = to_char(published_at::timestamptz AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS') || '+0000'
+		WHERE published_at ~ '^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}[+-]\d{4}$' AND published_at NOT LIKE '%+0000'`).Error; err != nil {
+		err = fmt.Errorf("published_at to UTC migration error: %w", err)
+	}
+	return
+}
 
+// Вакансии без даты публикации (записи лент без pubDate) датируются первым появлением:
+// уже известные сохраняют дату из БД, иначе каждая выгрузка делала бы их свежими
+func (ja JobAnnounces) fillFirstSeen(ctx context.Context) (err error) {
+	var ids []uint
+	for _, a := range ja {
+		if a.PublishedAt == "" {
+			ids = append(ids, a.ItemId)
 		}
-	} else {
-		if len(shownAnnounces) != 0 {
-
-			if err = DB.Socket.Limit(50).Where("LOWER(name) like ? and expierence = ? and schedule = ? and  item_id not in ? and area in ?", "%"+strings.ToLower(ud.VacancyName)+"%", expierence, ud.Schedule, shownAnnouncesIDs, locationsTarget).Find(&announces).Error; err != nil {
-				err = fmt.Errorf("db vacancy with param schedule getting error: %w", err)
-				return
-			}
-
-		} else {
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
-			if err = DB.Socket.Limit(50).Where("LOWER(name) like ? and expierence = ? and schedule = ?  and area in ?", "%"+strings.ToLower(ud.VacancyName)+"%", expierence, ud.Schedule, locationsTarget).Find(&announces).Error; err != nil {
-				err = fmt.Errorf("db vacancy with param schedule getting error: %w", err)
-				return
-			}
+	var known JobAnnounces
+	if err = DB.Socket.WithContext(ctx).Select("item_id", "published_at").Where("item_id in ?", ids).Find(&known).Error; err != nil {
+		return fmt.Errorf("job announces first seen getting error: %w", err)
+	}
+	firstSeen := make(map[uint]string, len(known))
+	for _, a := range known {
+		firstSeen[a.ItemId] = a.PublishedAt
+	}
 
+	now := time.Now().Format(PublishedAtLayout)
+	for i := range ja {
+		if ja[i].PublishedAt != "" {
+			continue
+		}
+		if ja[i].PublishedAt = firstSeen[ja[i].ItemId]; ja[i].PublishedAt == "" {
+			ja[i].PublishedAt = now
 		}
 	}
-
-	return
+	return nil
 }
 
-// ------------------------------------------------------->>>JobData-----------------------
-
-func CreatePivotVacancyAnnouncesAndUserIds(jobAnnouncesIDs []uint, uid uint) (err error) {
-	var tempPivot []UserPivotVacancy
-	for _, id := range jobAnnouncesIDs {
-		tempPivot = append(tempPivot, UserPivotVacancy{UID: uid, JobID: id})
-	}
-	if err = DB.Socket.Create(&tempPivot).Error; err != nil {
-		err = fmt.Errorf("db vacancy-user pivot record writing error: %w", err)
+// Дата публикации самой свежей вакансии
+func (ja JobAnnounces) LatestPublishedAt() (latest time.Time) {
+	for _, a := range ja {
+		published, err := time.Parse(PublishedAtLayout, a.PublishedAt)
+		if err != nil {
+			continue
+		}
+		if published.After(latest) {
+			latest = published
+		}
 	}
 	return
 }
+
+// Сквозной ИД вакансии стороннего источника.
+// ИД ХэХа меньше 2^40, поэтому прочие источники занимают диапазон выше: 2^40 + хеш от источника и его ИД
+func ItemIDFor(source, sourceID string) uint {
+	h := fnv.New64a()
+	h.Write([]byte(source + ":" + sourceID))
+	return foreignItemIDBase | uint(h.Sum64()&(foreignItemIDBase-1))
+}
+
+// ------------------------------------------------------->>>JobData-----------------------
@@ -1,8 +1,12 @@
 package bd_test
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strconv"
 	"testing"
+	"time"
 	"vacancydealer/bd"
 )
 
@@ -44,3 +48,211 @@ func TestMakeVacNameSearchPatternPOOL(t *testing.T) {
 	}
 
 }
+
+// Запросы с OR и NOT не поглощаются шаблоном-подстрокой и сами не поглощают другие
+func TestMakeVacNameSearchPatternPOOLQueries(t *testing.T) {
+	cases := []bd.UserDataList{
+		{{VacancyName: "golang OR rust"}, {VacancyName: "rust"}},
+		{{VacancyName: "golang NOT 1С"}, {VacancyName: "1С"}},
+	}
+	for _, ud := range cases {
+		pool := make(map[string]bool)
+		for _, p := range ud.MakeVacNameSearchPatternPOOL() {
+			pool[p.VacancyName] = true
+		}
+		for _, d := range ud {
+			if !pool[d.VacancyName] {
+				t.Errorf("Pattern %q is missing from pool %v", d.VacancyName, pool)
+			}
+		}
+	}
+}
+
+func TestMakeFingerprint(t *testing.T) {
+	hhAnnounce := bd.JobAnnounce{Name: "Golang-разработчик", Company: "ООО «Ромашка»", Area: 1, SalaryFrom: 201000, SalaryCurrency: "RUR"}
+	sjAnnounce := bd.JobAnnounce{Name: "golang разработчик", Company: "Ромашка", Area: 1, SalaryFrom: 200000, SalaryTo: 300000, SalaryCurrency: "RUR"}
+	if hhAnnounce.MakeFingerprint() != sjAnnounce.MakeFingerprint() {
+		t.Errorf("Fingerprints must be equal: %s != %s", hhAnnounce.MakeFingerprint(), sjAnnounce.MakeFingerprint())
+	}
+
+	otherCity := sjAnnounce
+	otherCity.Area = 2
+	if otherCity.MakeFingerprint() == sjAnnounce.MakeFingerprint() {
+		t.Errorf("Fingerprints of different cities must differ: %s", otherCity.MakeFingerprint())
+	}
+
+	if fp := (bd.JobAnnounce{Name: "Менеджер", Area: 1}).MakeFingerprint(); fp != "" {
+		t.Errorf("Fingerprint without employer must be empty, got %s", fp)
+	}
+}
+
+func TestCurrenciesConvert(t *testing.T) {
+	cur := bd.Currencies{
+		{Code: "RUR", Rate: 1},
+		{Code: "USD", Rate: 0.0125},
+		{Code: "EUR", Rate: 0.01},
+		{Code: "XXX", Rate: 0},
+	}
+
+	cases := []struct {
+		amount   float64
+		from, to string
+		expected float64
+		ok       bool
+	}{
+		{1000, "USD", "RUR", 80000, true},
+		{80000, "RUR", "USD", 1000, true},
+		{1000, "EUR", "USD", 1250, true},
+		{500, "KZT", "KZT", 500, true},
+		{1000, "KZT", "RUR", 0, false},
+		{1000, "XXX", "RUR", 0, false},
+	}
+
+	for _, c := range cases {
+		got, ok := cur.Convert(c.amount, c.from, c.to)
+		if ok != c.ok || (ok && (got-c.expected > 1e-6 || c.expected-got > 1e-6)) {
+			t.Errorf("Convert(%v %s -> %s) = %v, %t; expected %v, %t", c.amount, c.from, c.to, got, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	now := time.Date(2024, 5, 10, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		user     bd.UserData
+		expected bool
+	}{
+		{bd.UserData{DeliveryMode: bd.DeliveryModeInstant}, true},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeInstant, QuietFrom: 23, QuietTo: 11}, false},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeHourly, QuietFrom: 10, QuietTo: 12}, false},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeHourly}, true},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeHourly, LastDigestAt: now.Add(-59 * time.Minute)}, false},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeHourly, LastDigestAt: now.Add(-time.Hour)}, true},
+		// дайджест в 9 еще не уходил сегодня
+		{bd.UserData{DeliveryMode: bd.DeliveryModeDaily, DigestHour: 9, LastDigestAt: now.AddDate(0, 0, -1)}, true},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeDaily, DigestHour: 9, LastDigestAt: now.Add(-time.Hour)}, false},
+		// час дайджеста сегодня еще не наступил, вчерашний отправлен
+		{bd.UserData{DeliveryMode: bd.DeliveryModeDaily, DigestHour: 18, LastDigestAt: now.Add(-16 * time.Hour)}, false},
+		{bd.UserData{DeliveryMode: bd.DeliveryModeDaily, DigestHour: 18, LastDigestAt: now.AddDate(0, 0, -2)}, true},
+	}
+	for i, c := range cases {
+		if got := c.user.DigestDue(now); got != c.expected {
+			t.Errorf("case %d: DigestDue = %t, expected %t", i, got, c.expected)
+		}
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 5, 10, hour, 30, 0, 0, time.UTC) }
+	night := bd.UserData{QuietFrom: 22, QuietTo: 8}
+	day := bd.UserData{QuietFrom: 13, QuietTo: 15}
+	cases := []struct {
+		user     bd.UserData
+		hour     int
+		expected bool
+	}{
+		{bd.UserData{}, 3, false},
+		{night, 23, true},
+		{night, 3, true},
+		{night, 8, false},
+		{night, 21, false},
+		{day, 13, true},
+		{day, 15, false},
+		{day, 3, false},
+	}
+	for i, c := range cases {
+		if got := c.user.InQuietHours(at(c.hour)); got != c.expected {
+			t.Errorf("case %d: InQuietHours = %t, expected %t", i, got, c.expected)
+		}
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	areas := bd.Countries{
+		{Count: bd.AreaEntity{ID: 113, Name: "Россия"}, Regions: bd.Regions{
+			{Region: bd.AreaEntity{ID: 1261, Name: "Свердловская область"}, Cities: bd.Cities{{ID: 3, Name: "Екатеринбург"}}},
+			{Region: bd.AreaEntity{ID: 2019, Name: "Московская область"}, Cities: bd.Cities{{ID: 2038, Name: "Подольск"}}},
+		}},
+		{Count: bd.AreaEntity{ID: 40, Name: "Казахстан"}},
+		{Count: bd.AreaEntity{ID: 1001, Name: "Другие регионы"}},
+	}
+	cases := map[uint]string{
+		3:    "Asia/Yekaterinburg",
+		1261: "Asia/Yekaterinburg",
+		2038: "Europe/Moscow",
+		40:   "Asia/Almaty",
+		1001: bd.DefaultTimezone,
+		0:    bd.DefaultTimezone,
+	}
+	for area, expected := range cases {
+		if got := areas.Timezone(area); got != expected {
+			t.Errorf("Timezone(%d) = %q, expected %q", area, got, expected)
+		}
+	}
+
+	u := bd.UserData{}
+	if got := u.TimeLocation(bd.Subscriptions{{Location: 0}, {Location: 3}}, areas).String(); got != "Asia/Yekaterinburg" {
+		t.Errorf("derived TimeLocation = %q, expected Asia/Yekaterinburg", got)
+	}
+	u.Timezone = "Asia/Tokyo"
+	if got := u.TimeLocation(bd.Subscriptions{{Location: 3}}, areas).String(); got != "Asia/Tokyo" {
+		t.Errorf("chosen TimeLocation = %q, expected Asia/Tokyo", got)
+	}
+}
+
+func TestExperienceCode(t *testing.T) {
+	cases := map[int]string{0: "noExperience", 1: "between1And3", 3: "between1And3", 4: "between3And6", 6: "between3And6", 7: "moreThan6", 15: "moreThan6"}
+	for years, expected := range cases {
+		if code := bd.ExperienceCode(years); code != expected {
+			t.Errorf("ExperienceCode(%d) = %q, expected %q", years, code, expected)
+		}
+	}
+}
+
+// Подключение к тестовой БД из TEST_DB_* переменных окружения, без них тест пропускается
+func testDB(t *testing.T) {
+	t.Helper()
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		t.Skip("TEST_DB_HOST is not set")
+	}
+	port, err := strconv.Atoi(os.Getenv("TEST_DB_PORT"))
+	if err != nil {
+		port = 5432
+	}
+	if err = bd.Init(host, os.Getenv("TEST_DB_USER"), os.Getenv("TEST_DB_PASSWORD"), os.Getenv("TEST_DB_NAME"), port, "disable"); err != nil {
+		t.Fatal(err)
+	}
+	if err = bd.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Пользователь, записанный до появления onboarding_step: после миграции он пройденный и получает рассылку
+func TestMigrateUserBeforeOnboarding(t *testing.T) {
+	testDB(t)
+	const tgID = -1001
+
+	u, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bd.DeleteUserData(context.Background(), tgID) })
+	if err = bd.DB.Socket.Exec("UPDATE user_data SET onboarding_step = NULL, active = true WHERE tg_id = ?", u.TgID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = bd.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := bd.GetActiveSubscriptions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range subs {
+		if s.TgID == tgID {
+			return
+		}
+	}
+	t.Error("user created before onboarding migration gets no subscriptions")
+}
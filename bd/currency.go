@@ -0,0 +1,57 @@
+package bd
+
+import (
+	"fmt"
+
+	"gorm.io/gorm/clause"
+)
+
+const BaseCurrency = "RUR" // валюта, к которой приведены курсы справочника
+
+// Запись справочника валют с обновлением курсов
+func (cur Currencies) SaveInDB() (err error) {
+	if len(cur) == 0 {
+		return nil
+	}
+	if err = DB.Socket.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"abbr", "name", "rate", "in_use", "updated_at"}),
+	}).Create(&cur).Error; err != nil {
+		err = fmt.Errorf("currencies saving error: %w", err)
+	}
+	return
+}
+
+// Справочник валют: сначала рубль, затем по коду
+func GetCurrencies() (cur Currencies, err error) {
+	if err = DB.Socket.Order(clause.OrderBy{Expression: clause.Expr{SQL: "code <> ?, code", Vars: []any{BaseCurrency}}}).Find(&cur).Error; err != nil {
+		err = fmt.Errorf("currencies getting error: %w", err)
+	}
+	return
+}
+
+func (cur Currencies) rate(code string) (rate float64, ok bool) {
+	for _, c := range cur {
+		if c.Code == code && c.Rate > 0 {
+			return c.Rate, true
+		}
+	}
+	return 0, false
+}
+
+// Пересчет суммы из одной валюты в другую через курсы к рублю.
+// ok == false, если курс какой-то из валют не известен
+func (cur Currencies) Convert(amount float64, from, to string) (converted float64, ok bool) {
+	if from == to {
+		return amount, true
+	}
+	fromRate, ok := cur.rate(from)
+	if !ok {
+		return
+	}
+	toRate, ok := cur.rate(to)
+	if !ok {
+		return
+	}
+	return amount / fromRate * toRate, true
+}
@@ -0,0 +1,125 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const fingerprintsBatchSize = 1000
+
+var legalFormRe = regexp.MustCompile(`(?i)(^|\s)(ооо|оао|зао|пао|ао|ип|нко|ано|llc|inc|ltd|gmbh|corp)(\s|$)`)
+
+// Отпечаток вакансии: нормализованные название и работодатель, локация, зарплатная вилка.
+// Без работодателя отпечаток пустой - такие вакансии не склеиваются
+func (a JobAnnounce) MakeFingerprint() string {
+	company := normalizeText(legalFormRe.ReplaceAllString(strings.ToLower(a.Company), " "))
+	if company == "" {
+		return ""
+	}
+	return strings.Join([]string{normalizeText(a.Name), company, strconv.Itoa(a.Area), salaryBand(a)}, "|")
+}
+
+// Склейка дублей перед записью.
+// Каждой вакансии проставляется отпечаток, повтор уже известной вакансии (из любого источника или этой же пачки)
+// получает CanonicalID - ИД первой записи с тем же отпечатком
+func (ja JobAnnounces) Deduplicate(ctx context.Context) (JobAnnounces, error) {
+	db := DB.Socket.WithContext(ctx)
+
+	itemIDs := make([]uint, 0, len(ja))
+	fingerprints := make([]string, 0, len(ja))
+	for i := range ja {
+		ja[i].Fingerprint = ja[i].MakeFingerprint()
+		ja[i].CanonicalID = 0
+		itemIDs = append(itemIDs, ja[i].ItemId)
+		if ja[i].Fingerprint != "" {
+			fingerprints = append(fingerprints, ja[i].Fingerprint)
+		}
+	}
+
+	known := make(map[uint]uint, len(ja))        // item_id -> canonical_id уже записанных вакансий
+	canonicals := make(map[string]uint, len(ja)) // отпечаток -> ИД канонической записи
+	for start := 0; start < len(ja); start += fingerprintsBatchSize {
+		end := min(start+fingerprintsBatchSize, len(ja))
+
+		var existing JobAnnounces
+		if err := db.Select("item_id", "canonical_id").Where("item_id in ?", itemIDs[start:end]).Find(&existing).Error; err != nil {
+			return ja, fmt.Errorf("dedup existing announces getting error: %w", err)
+		}
+		for _, e := range existing {
+			known[e.ItemId] = e.CanonicalID
+		}
+	}
+	for start := 0; start < len(fingerprints); start += fingerprintsBatchSize {
+		end := min(start+fingerprintsBatchSize, len(fingerprints))
+
+		var originals JobAnnounces
+		if err := db.Select("item_id", "fingerprint").Where("fingerprint in ? and canonical_id = 0", fingerprints[start:end]).Order("item_id").Find(&originals).Error; err != nil {
+			return ja, fmt.Errorf("dedup canonical announces getting error: %w", err)
+		}
+		for _, o := range originals {
+			if _, ok := canonicals[o.Fingerprint]; !ok {
+				canonicals[o.Fingerprint] = o.ItemId
+			}
+		}
+	}
+
+	for i, a := range ja {
+		if canonicalID, ok := known[a.ItemId]; ok {
+			ja[i].CanonicalID = canonicalID
+			continue
+		}
+		if a.Fingerprint == "" {
+			continue
+		}
+		if canonicalID, ok := canonicals[a.Fingerprint]; ok && canonicalID != a.ItemId {
+			ja[i].CanonicalID = canonicalID
+			continue
+		}
+		canonicals[a.Fingerprint] = a.ItemId
+	}
+	return ja, nil
+}
+
+// Дубли канонических вакансий: ИД канонической -> ее дубли
+func GetDuplicates(canonicalIDs []uint) (duplicates map[uint]JobAnnounces, err error) {
+	duplicates = make(map[uint]JobAnnounces)
+	if len(canonicalIDs) == 0 {
+		return
+	}
+
+	var announces JobAnnounces
+	if err = DB.Socket.Where("canonical_id in ?", canonicalIDs).Find(&announces).Error; err != nil {
+		err = fmt.Errorf("duplicate announces getting error: %w", err)
+		return
+	}
+	for _, a := range announces {
+		duplicates[a.CanonicalID] = append(duplicates[a.CanonicalID], a)
+	}
+	return
+}
+
+// Нижняя граница вилки, округленная до двух значащих цифр, с валютой
+func salaryBand(a JobAnnounce) string {
+	salary := a.SalaryFrom
+	if salary == 0 {
+		salary = a.SalaryTo
+	}
+	if salary <= 0 {
+		return "-"
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(salary))-1)
+	return strconv.FormatFloat(math.Round(salary/magnitude)*magnitude, 'f', 0, 64) + strings.ToUpper(a.SalaryCurrency)
+}
+
+// Нижний регистр, ё -> е, только буквы и цифры, одиночные пробелы
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	return strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
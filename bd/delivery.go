@@ -0,0 +1,76 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Статусы доставки вакансии пользователю
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed" // отправка не удалась, вакансия будет предложена снова
+	DeliveryQueued = "queued" // ждет дайджеста
+)
+
+// После стольких неудачных отправок вакансия больше не предлагается
+const deliveryMaxAttempts = 3
+
+// старый уникальный индекс только по вакансии: вакансия доставлялась лишь первому пользователю
+const legacyDeliveryIndex = "idx_user_pivot_vacancies_job_id"
+
+// Отметки доставки вакансий пользователю uid. Повторная запись той же вакансии
+// обновляет статус, время и сообщение, поэтому запись идемпотентна.
+// Неудачные отправки копятся в attempts
+func SaveDeliveries(ctx context.Context, uid uint, deliveries []UserPivotVacancy) (err error) {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	for i := range deliveries {
+		deliveries[i].UID = uid
+		deliveries[i].Attempts = 0
+		if deliveries[i].Status == DeliveryFailed {
+			deliveries[i].Attempts = 1
+		}
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}, {Name: "job_id"}},
+		DoUpdates: append(clause.AssignmentColumns([]string{"status", "sent_at", "message_id", "score", "updated_at", "deleted_at"}),
+			clause.Assignment{Column: clause.Column{Name: "attempts"}, Value: gorm.Expr("user_pivot_vacancies.attempts + excluded.attempts")}),
+	}
+	if err = DB.Socket.WithContext(ctx).Clauses(onConflict).CreateInBatches(&deliveries, jobAnnouncesBatchSize).Error; err != nil {
+		err = fmt.Errorf("user %d deliveries writing error: %w", uid, err)
+	}
+	return
+}
+
+// До AutoMigrate: снятие старого индекса по вакансии и удаление повторов пары пользователь-вакансия,
+// иначе новый уникальный индекс не создастся
+func migrateDeliveriesIndex() (err error) {
+	m := DB.Socket.Migrator()
+	if !m.HasTable(&UserPivotVacancy{}) {
+		return nil
+	}
+
+	if m.HasIndex(&UserPivotVacancy{}, legacyDeliveryIndex) {
+		if err = m.DropIndex(&UserPivotVacancy{}, legacyDeliveryIndex); err != nil {
+			return fmt.Errorf("legacy deliveries index dropping error: %w", err)
+		}
+	}
+
+	if err = DB.Socket.Exec("DELETE FROM user_pivot_vacancies a USING user_pivot_vacancies b WHERE a.uid = b.uid AND a.job_id = b.job_id AND a.id > b.id").Error; err != nil {
+		return fmt.Errorf("duplicate deliveries deleting error: %w", err)
+	}
+	return nil
+}
+
+// После AutoMigrate: время доставки для записей, сделанных до его появления
+func migrateDeliveriesSentAt() (err error) {
+	if err = DB.Socket.Exec("UPDATE user_pivot_vacancies SET sent_at = created_at WHERE sent_at IS NULL OR sent_at = '0001-01-01'").Error; err != nil {
+		err = fmt.Errorf("deliveries sent_at backfill error: %w", err)
+	}
+	return
+}
@@ -0,0 +1,97 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Режимы доставки вакансий пользователю
+const (
+	DeliveryModeInstant = "instant" // каждая вакансия отдельным сообщением
+	DeliveryModeHourly  = "hourly"  // дайджест раз в час
+	DeliveryModeDaily   = "daily"   // дайджест раз в день в DigestHour
+)
+
+// Вакансия дайджеста с ее оценкой
+type DigestItem struct {
+	JobAnnounce
+	Score int
+}
+
+// Смена режима доставки. При переходе на мгновенную доставку ожидавшие дайджеста вакансии
+// забываются и приходят отдельными сообщениями
+func (u UserData) SetDeliveryMode(mode string, digestHour int) (err error) {
+	err = DB.Socket.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&UserData{}).Where("tg_id=?", u.TgID).Updates(map[string]any{"delivery_mode": mode, "digest_hour": digestHour}).Error; err != nil {
+			return err
+		}
+		if mode != DeliveryModeInstant {
+			return nil
+		}
+		return tx.Unscoped().Where("uid = ? and status = ?", u.TgID, DeliveryQueued).Delete(&UserPivotVacancy{}).Error
+	})
+	if err != nil {
+		err = fmt.Errorf("user %d delivery mode updating error: %w", u.TgID, err)
+	}
+	return
+}
+
+// Пора ли отправлять накопленные вакансии, now - по местному времени пользователя.
+// Ежедневный дайджест уходит в первый цикл после DigestHour, при мгновенной доставке
+// накопленное за тихие часы уходит сразу после них
+func (u UserData) DigestDue(now time.Time) bool {
+	if u.InQuietHours(now) {
+		return false
+	}
+	switch u.DeliveryMode {
+	case DeliveryModeHourly:
+		return now.Sub(u.LastDigestAt) >= time.Hour
+	case DeliveryModeDaily:
+		scheduled := time.Date(now.Year(), now.Month(), now.Day(), u.DigestHour, 0, 0, 0, now.Location())
+		if now.Before(scheduled) {
+			scheduled = scheduled.AddDate(0, 0, -1)
+		}
+		return u.LastDigestAt.Before(scheduled)
+	}
+	return true
+}
+
+// Страница вакансий дайджеста по убыванию оценки и их общее число.
+// messageID 0 - вакансии, ожидающие следующего дайджеста, иначе - отправленные в этом сообщении
+func GetDigestItems(ctx context.Context, uid uint, messageID, offset, limit int) (items []DigestItem, total int64, err error) {
+	digest := func() *gorm.DB {
+		query := DB.Socket.WithContext(ctx).Table("job_announces").
+			Joins("join user_pivot_vacancies on user_pivot_vacancies.job_id = job_announces.item_id").
+			Where("user_pivot_vacancies.uid = ? and user_pivot_vacancies.deleted_at is null", uid)
+		if messageID == 0 {
+			return query.Where("user_pivot_vacancies.status = ?", DeliveryQueued)
+		}
+		return query.Where("user_pivot_vacancies.status = ? and user_pivot_vacancies.message_id = ?", DeliverySent, messageID)
+	}
+
+	if err = digest().Count(&total).Error; err != nil {
+		err = fmt.Errorf("user %d digest counting error: %w", uid, err)
+		return
+	}
+	if err = digest().Select("job_announces.*, user_pivot_vacancies.score").Order("user_pivot_vacancies.score desc, job_announces.item_id").Offset(offset).Limit(limit).Find(&items).Error; err != nil {
+		err = fmt.Errorf("user %d digest getting error: %w", uid, err)
+	}
+	return
+}
+
+// Ожидавшие вакансии отправлены дайджестом в сообщении messageID
+func MarkDigestSent(ctx context.Context, uid uint, messageID int, sentAt time.Time) (err error) {
+	err = DB.Socket.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&UserPivotVacancy{}).Where("uid = ? and status = ?", uid, DeliveryQueued).Updates(map[string]any{"status": DeliverySent, "message_id": messageID, "sent_at": sentAt}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&UserData{}).Where("tg_id = ?", uid).Update("last_digest_at", sentAt).Error
+	})
+	if err != nil {
+		err = fmt.Errorf("user %d digest marking error: %w", uid, err)
+	}
+	return
+}
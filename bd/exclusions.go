@@ -0,0 +1,110 @@
+package bd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Стоп-слова из ввода пользователя: через запятую, без пустых и повторов, в нижнем регистре
+func ParseExcludeWords(input string) (words []string) {
+	seen := make(map[string]bool)
+	for _, w := range strings.Split(input, ",") {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" || seen[w] {
+			continue
+		}
+		seen[w] = true
+		words = append(words, w)
+	}
+	return
+}
+
+func (u UserData) UpdateExcludeWords(words []string) (err error) {
+	if err = DB.Socket.Model(&UserData{}).Where("tg_id=?", u.TgID).Update("exclude_words", strings.Join(words, ",")).Error; err != nil {
+		err = fmt.Errorf("user %d exclude words updating error: %w", u.TgID, err)
+	}
+	return
+}
+
+func GetBlockedEmployers(tgID int64) (employers BlockedEmployers, err error) {
+	if err = DB.Socket.Where("tg_id=?", tgID).Order("company").Find(&employers).Error; err != nil {
+		err = fmt.Errorf("user %d blocked employers getting error: %w", tgID, err)
+	}
+	return
+}
+
+// Скрытие работодателя. Повторное скрытие не ошибка
+func BlockEmployer(tgID int64, company string) (err error) {
+	if err = DB.Socket.Clauses(clause.OnConflict{DoNothing: true}).Create(&BlockedEmployer{TgID: tgID, Company: company}).Error; err != nil {
+		err = fmt.Errorf("employer %q blocking error: %w", company, err)
+	}
+	return
+}
+
+func UnblockEmployer(tgID int64, id uint) (err error) {
+	if err = DB.Socket.Where("tg_id=? and id=?", tgID, id).Delete(&BlockedEmployer{}).Error; err != nil {
+		err = fmt.Errorf("employer %d unblocking error: %w", id, err)
+	}
+	return
+}
+
+// Стоп-слова и скрытые работодатели пользователя
+func GetUserExclusions(tgID int64) (ex Exclusions, err error) {
+	var u UserData
+	if err = DB.Socket.Where("tg_id=?", tgID).First(&u).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		err = fmt.Errorf("user %d exclusions getting error: %w", tgID, err)
+		return
+	}
+	ex.Words = ParseExcludeWords(u.ExcludeWords)
+
+	employers, err := GetBlockedEmployers(tgID)
+	if err != nil {
+		return
+	}
+	for _, e := range employers {
+		ex.Employers = append(ex.Employers, strings.ToLower(e.Company))
+	}
+	return ex, nil
+}
+
+// Отсев по исключениям в запросе вакансий
+func (ex Exclusions) condition(query *gorm.DB) *gorm.DB {
+	for _, w := range ex.Words {
+		pattern := "%" + w + "%"
+		query = query.Where("LOWER(name) not like ? and LOWER(requirement) not like ? and LOWER(responsebility) not like ?", pattern, pattern, pattern)
+	}
+	if len(ex.Employers) != 0 {
+		query = query.Where("LOWER(company) not in ?", ex.Employers)
+	}
+	return query
+}
+
+// Исключена ли вакансия. Для выдачи живого поиска, которую нельзя отфильтровать запросом
+func (ex Exclusions) Excludes(a JobAnnounce) bool {
+	company := strings.ToLower(a.Company)
+	for _, e := range ex.Employers {
+		if company == e {
+			return true
+		}
+	}
+
+	text := strings.ToLower(a.Name + " " + a.Requirement + " " + a.Responsebility)
+	for _, w := range ex.Words {
+		if strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Вакансия по сквозному ИД
+func FindJobAnnounce(itemID uint) (a JobAnnounce, err error) {
+	if err = DB.Socket.Where("item_id=?", itemID).First(&a).Error; err != nil {
+		err = fmt.Errorf("job announce %d finding error: %w", itemID, err)
+	}
+	return
+}
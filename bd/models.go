@@ -1,6 +1,10 @@
 package bd
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type (
 	DBentity struct {
@@ -11,40 +15,137 @@ type (
 )
 
 type (
+	// Поля фильтра остались от времен одного поиска на пользователя:
+	// при миграции они переносятся в подписку, дальше поиск ведется по Subscription
 	UserData struct {
 		gorm.Model
 		TgID           int64 `gorm:"uniqueIndex"`
+		Active         bool  `gorm:"default:true"` // false - рассылка приостановлена командой /pause
 		VacancyName    string
 		ExperienceYear int
 		Schedule       string
 		Location       uint
+		// стоп-слова через запятую: вакансии с ними в названии или описании не показываются
+		ExcludeWords string
+		DeliveryMode string `gorm:"default:instant"` // DeliveryModeInstant, DeliveryModeHourly, DeliveryModeDaily
+		DigestHour   int    `gorm:"default:9"`       // час ежедневного дайджеста
+		LastDigestAt time.Time
+		Timezone     string // IANA, пустой - по локации подписок
+		// тихие часы по местному времени, вакансии в них копятся до конца окна. Равные - выключены
+		QuietFrom int
+		QuietTo   int
+		// шаг знакомства с ботом, на котором остановился пользователь. 0 - пройдено
+		OnboardingStep uint8 `gorm:"default:0"`
 	}
 
 	UserDataList []UserData
 
-	JobAnnounce struct {
-		ItemId         uint   `gorm:"primaryKey"`
-		Name           string `gorm:"index"`
-		Company        string
-		Area           int
-		Expierence     string
-		SalaryGross    bool
-		SalaryFrom     float64
-		SalaryTo       float64
-		SalaryCurrency string
-		PublishedAt    string
+	// Работодатель, скрытый пользователем
+	BlockedEmployer struct {
+		ID        uint   `gorm:"primaryKey"`
+		TgID      int64  `gorm:"uniqueIndex:idx_blocked_employer"`
+		Company   string `gorm:"uniqueIndex:idx_blocked_employer"`
+		CreatedAt time.Time
+	}
+
+	BlockedEmployers []BlockedEmployer
+
+	// Состояние диалога с пользователем: какое значение бот ждет следующим сообщением
+	UserState struct {
+		TgID      int64 `gorm:"primaryKey;autoIncrement:false"`
+		State     uint8
+		SubID     uint      // подписка, которую редактирует пользователь
+		ItemID    uint      // вакансия, к которой пишется заметка
+		UpdatedAt time.Time `gorm:"index"`
+	}
+
+	// Исключения пользователя, общие для всех его подписок
+	Exclusions struct {
+		Words     []string
+		Employers []string
+	}
+
+	// Сохраненный поиск пользователя, у пользователя их может быть несколько
+	Subscription struct {
+		gorm.Model
+		TgID           int64 `gorm:"index"`
+		Name           string
+		Active         bool `gorm:"default:true"`
+		VacancyName    string
+		ExperienceYear int
 		Schedule       string
-		Requirement    string
-		Responsebility string
-		Link           string
+		Location       uint
+		// минимальная желаемая зарплата, 0 - не важна.
+		// SalaryGross - сумма указана до вычета НДФЛ
+		SalaryMin      int
+		SalaryCurrency string `gorm:"default:RUR"`
+		SalaryGross    bool
+		OnlyWithSalary bool
+	}
+
+	Subscriptions []Subscription
+
+	// ItemId - сквозной ИД вакансии: для hh совпадает с ИД ХэХа, для прочих источников см. ItemIDFor
+	JobAnnounce struct {
+		ItemId          uint   `gorm:"primaryKey"`
+		Source          string `gorm:"index;default:hh"`
+		SourceID        string
+		Name            string `gorm:"index"`
+		NameNorm        string // название в нормальной форме textnorm.Normalize
+		Company         string
+		EmployerTrusted bool // работодатель проверен источником
+		Area            int
+		Expierence      string
+		SalaryGross     bool
+		SalaryFrom      float64
+		SalaryTo        float64
+		SalaryCurrency  string
+		PublishedAt     string
+		Schedule        string
+		Requirement     string
+		Responsebility  string
+		Link            string
+		Fingerprint     string `gorm:"index"`
+		CanonicalID     uint   `gorm:"index;default:0"` // 0 - каноническая запись, иначе ИД вакансии, дублем которой является
+		VacancyDetails  `gorm:"embedded"`
 	}
 
 	JobAnnounces []JobAnnounce
 
+	// Полная карточка вакансии, догружается очередью подробностей после выгрузки
+	VacancyDetails struct {
+		Description       string
+		KeySkills         string // через запятую
+		Employment        string
+		Address           string
+		HasContacts       bool
+		ProfessionalRoles string // через запятую
+		Archived          bool
+		DetailsFetchedAt  *time.Time `gorm:"index"` // nil - подробности еще не загружены
+		DetailsAttempts   int        `gorm:"default:0"`
+	}
+
+	// Доставка вакансии пользователю: одна запись на пару пользователь-вакансия
 	UserPivotVacancy struct {
 		gorm.Model
-		UID   uint
-		JobID uint `gorm:"uniqueIndex"`
+		UID       uint   `gorm:"uniqueIndex:idx_user_pivot_vacancy"`
+		JobID     uint   `gorm:"uniqueIndex:idx_user_pivot_vacancy"`
+		Status    string `gorm:"index;default:sent"` // DeliverySent, DeliveryFailed, DeliveryQueued
+		SentAt    time.Time
+		MessageID int // ИД сообщения с карточкой или дайджестом в чате пользователя
+		Score     int // оценка вакансии для пользователя на момент отбора
+		Attempts  int // неудачных отправок, после deliveryMaxAttempts вакансия не предлагается
+	}
+
+	// Вакансия в избранном пользователя с заметкой
+	SavedVacancy struct {
+		ID        uint  `gorm:"primaryKey"`
+		TgID      int64 `gorm:"uniqueIndex:idx_saved_vacancy"`
+		JobID     uint  `gorm:"uniqueIndex:idx_saved_vacancy"`
+		Note      string
+		Status    string     `gorm:"index;default:active"` // SavedActive, SavedArchived, SavedRemoved
+		CheckedAt *time.Time // nil - еще не проверялась на источнике
+		CreatedAt time.Time
 	}
 
 	CountrySQL struct {
@@ -100,4 +201,55 @@ type (
 	}
 
 	VacancyNamePatterns []VacancynameSearchPattern
+
+	// Сопоставление локации стороннего источника с деревом локаций
+	SourceArea struct {
+		Source   string `gorm:"primaryKey"`
+		SourceID string `gorm:"primaryKey"`
+		AreaID   uint
+	}
+
+	SourceAreas []SourceArea
+
+	// Каталог профессий стороннего источника
+	Catalogue struct {
+		Source   string `gorm:"primaryKey"`
+		SourceID string `gorm:"primaryKey"`
+		Title    string
+		ParentID string
+	}
+
+	Catalogues []Catalogue
+
+	// RSS/Atom лента вакансий. Company - имя компании для всех записей ленты, если пусто - угадывается из записи
+	JobFeed struct {
+		ID           uint   `gorm:"primaryKey"`
+		URL          string `gorm:"uniqueIndex"`
+		Company      string
+		Enabled      bool `gorm:"default:true"`
+		LastPolledAt time.Time
+	}
+
+	JobFeeds []JobFeed
+
+	// Валюта из справочника ХэХа. Rate - курс к рублю: сколько единиц валюты за 1 RUR
+	Currency struct {
+		Code      string `gorm:"primaryKey"`
+		Abbr      string
+		Name      string
+		Rate      float64
+		InUse     bool
+		UpdatedAt time.Time
+	}
+
+	Currencies []Currency
+
+	// Отметка выгрузки шаблона поиска из источника: дата публикации самой свежей вакансии и время синхронизаций
+	HarvestWatermark struct {
+		Source          string `gorm:"primaryKey"`
+		Pattern         string `gorm:"primaryKey"`
+		LastPublishedAt time.Time
+		LastSyncAt      time.Time
+		LastFullSyncAt  time.Time
+	}
 )
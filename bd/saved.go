@@ -0,0 +1,83 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Состояние сохраненной вакансии на источнике
+const (
+	SavedActive   = "active"
+	SavedArchived = "archived" // вакансия перенесена в архив
+	SavedRemoved  = "removed"  // вакансия удалена с источника
+)
+
+// Вакансия из избранного с заметкой и состоянием на источнике
+type SavedItem struct {
+	JobAnnounce
+	Note   string
+	Status string
+}
+
+// Сохранение вакансии в избранное. Повторное сохранение не ошибка
+func SaveVacancy(ctx context.Context, tgID int64, jobID uint) (err error) {
+	if err = DB.Socket.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&SavedVacancy{TgID: tgID, JobID: jobID}).Error; err != nil {
+		err = fmt.Errorf("user %d vacancy %d saving error: %w", tgID, jobID, err)
+	}
+	return
+}
+
+func UnsaveVacancy(ctx context.Context, tgID int64, jobID uint) (err error) {
+	if err = DB.Socket.WithContext(ctx).Where("tg_id = ? and job_id = ?", tgID, jobID).Delete(&SavedVacancy{}).Error; err != nil {
+		err = fmt.Errorf("user %d saved vacancy %d deleting error: %w", tgID, jobID, err)
+	}
+	return
+}
+
+// Заметка к сохраненной вакансии, пустая строка удаляет заметку
+func SetSavedNote(ctx context.Context, tgID int64, jobID uint, note string) (err error) {
+	if err = DB.Socket.WithContext(ctx).Model(&SavedVacancy{}).Where("tg_id = ? and job_id = ?", tgID, jobID).Update("note", note).Error; err != nil {
+		err = fmt.Errorf("user %d saved vacancy %d note updating error: %w", tgID, jobID, err)
+	}
+	return
+}
+
+// Страница избранного пользователя, новые сохранения первыми, и общее число сохраненных
+func GetSavedVacancies(ctx context.Context, tgID int64, offset, limit int) (items []SavedItem, total int64, err error) {
+	saved := func() *gorm.DB {
+		return DB.Socket.WithContext(ctx).Table("job_announces").
+			Joins("join saved_vacancies on saved_vacancies.job_id = job_announces.item_id").
+			Where("saved_vacancies.tg_id = ?", tgID)
+	}
+
+	if err = saved().Count(&total).Error; err != nil {
+		err = fmt.Errorf("user %d saved vacancies counting error: %w", tgID, err)
+		return
+	}
+	if err = saved().Select("job_announces.*, saved_vacancies.note, saved_vacancies.status").Order("saved_vacancies.created_at desc, saved_vacancies.id desc").Offset(offset).Limit(limit).Find(&items).Error; err != nil {
+		err = fmt.Errorf("user %d saved vacancies getting error: %w", tgID, err)
+	}
+	return
+}
+
+// Очередь проверки избранного: активные вакансии указанных источников,
+// не проверявшиеся с checkedBefore. Вакансия, сохраненная несколькими пользователями, - одна
+func GetSavedCheckQueue(ctx context.Context, sources []string, checkedBefore time.Time, limit int) (queue JobAnnounces, err error) {
+	pending := DB.Socket.WithContext(ctx).Model(&SavedVacancy{}).Select("job_id").Where("status = ? and (checked_at is null or checked_at < ?)", SavedActive, checkedBefore)
+	if err = DB.Socket.WithContext(ctx).Where("item_id in (?) and source in ?", pending, sources).Order("item_id").Limit(limit).Find(&queue).Error; err != nil {
+		err = fmt.Errorf("saved vacancies check queue getting error: %w", err)
+	}
+	return
+}
+
+// Результат проверки вакансии на источнике для всех сохранивших ее пользователей
+func MarkSavedChecked(ctx context.Context, jobID uint, status string, checkedAt time.Time) (err error) {
+	if err = DB.Socket.WithContext(ctx).Model(&SavedVacancy{}).Where("job_id = ? and status = ?", jobID, SavedActive).Updates(map[string]any{"status": status, "checked_at": checkedAt}).Error; err != nil {
+		err = fmt.Errorf("saved vacancy %d check marking error: %w", jobID, err)
+	}
+	return
+}
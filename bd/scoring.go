@@ -0,0 +1,97 @@
+package bd
+
+import (
+	"sort"
+	"time"
+	"vacancydealer/querylang"
+	"vacancydealer/scoring"
+	"vacancydealer/textnorm"
+)
+
+// Вакансия с оценкой для пользователя
+type ScoredAnnounce struct {
+	JobAnnounce
+	Score scoring.Result
+}
+
+// Лучшие limit вакансий подписки по убыванию оценки.
+// При равной оценке сохраняется порядок выдачи из БД - по релевантности запросу
+func (s Subscription) Rank(announces JobAnnounces, areas Countries, cur Currencies, limit int, now time.Time) []ScoredAnnounce {
+	e := s.scoringExpectation()
+	scored := make([]ScoredAnnounce, 0, len(announces))
+	for _, a := range announces {
+		scored = append(scored, ScoredAnnounce{JobAnnounce: a, Score: scoring.Score(s.scoringVacancy(a, areas, cur), e, now)})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score.Score > scored[j].Score.Score
+	})
+	if limit > 0 && len(scored) > limit {
+		scored = scored[:limit]
+	}
+	return scored
+}
+
+func (s Subscription) scoringExpectation() (e scoring.Expectation) {
+	if q, err := querylang.Parse(s.VacancyName); err == nil {
+		for _, t := range q.Terms() {
+			if norm := textnorm.Normalize(t); norm != "" {
+				e.Terms = append(e.Terms, norm)
+			}
+		}
+	}
+	e.SalaryMin = float64(s.SalaryMin)
+	return
+}
+
+func (s Subscription) scoringVacancy(a JobAnnounce, areas Countries, cur Currencies) scoring.Vacancy {
+	v := scoring.Vacancy{NameNorm: a.NameNorm, Location: s.locationDistance(a.Area, areas), Trusted: a.EmployerTrusted}
+	if a.NameNorm == "" {
+		v.NameNorm = textnorm.Normalize(a.Name)
+	}
+	if published, err := time.Parse(PublishedAtLayout, a.PublishedAt); err == nil {
+		v.PublishedAt = published
+	}
+
+	// верхняя граница вилки в валюте пользователя и той же "грязности"
+	if salary := max(a.SalaryFrom, a.SalaryTo); salary > 0 {
+		if converted, ok := cur.Convert(salary, a.SalaryCurrency, s.SalaryCurrency); ok || a.SalaryCurrency == s.SalaryCurrency {
+			if ok {
+				salary = converted
+			}
+			switch {
+			case a.SalaryGross && !s.SalaryGross:
+				salary *= 1 - incomeTaxRate
+			case !a.SalaryGross && s.SalaryGross:
+				salary /= 1 - incomeTaxRate
+			}
+			v.Salary = salary
+		}
+	}
+	return v
+}
+
+// Удаленность вакансии от локации подписки: внутри нее, в том же регионе, в той же стране
+func (s Subscription) locationDistance(area int, areas Countries) scoring.Location {
+	if s.Location == 0 || area == 0 {
+		return scoring.LocationUnknown
+	}
+
+	for _, id := range areas.FindContainLocationIDsList(s.Location) {
+		if id == uint(area) {
+			return scoring.LocationExact
+		}
+	}
+
+	userCountry, userRegion, _ := areas.FindLocationByAreaID(int(s.Location))
+	country, region, _ := areas.FindLocationByAreaID(area)
+	switch {
+	case country == nil || userCountry == nil:
+		return scoring.LocationUnknown
+	case region != nil && userRegion != nil && region.ID == userRegion.ID:
+		return scoring.LocationSameRegion
+	case country.ID == userCountry.ID:
+		return scoring.LocationSameCountry
+	}
+	return scoring.LocationFar
+}
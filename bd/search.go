@@ -0,0 +1,54 @@
+package bd
+
+import (
+	"fmt"
+	"vacancydealer/logger"
+	"vacancydealer/textnorm"
+
+	"gorm.io/gorm"
+)
+
+// Полнотекстовый поиск по названию и сниппету: русская и английская морфология,
+// название с весом A, требования и обязанности - B
+const searchVectorSQL = `setweight(to_tsvector('russian', coalesce(name, '')), 'A') || setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('russian', coalesce(requirement, '') || ' ' || coalesce(responsebility, '')), 'B') ||
+	setweight(to_tsvector('english', coalesce(requirement, '') || ' ' || coalesce(responsebility, '')), 'B')`
+
+// Вычисляемая колонка search_vector с GIN индексом. Колонки нет в модели JobAnnounce:
+// ее пишет сама БД, а AutoMigrate не трогает незнакомые колонки
+func migrateSearchVector() (err error) {
+	if err = DB.Socket.Exec("ALTER TABLE job_announces ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (" + searchVectorSQL + ") STORED").Error; err != nil {
+		return fmt.Errorf("search_vector column creating error: %w", err)
+	}
+	if err = DB.Socket.Exec("CREATE INDEX IF NOT EXISTS idx_job_announces_search_vector ON job_announces USING gin (search_vector)").Error; err != nil {
+		return fmt.Errorf("search_vector index creating error: %w", err)
+	}
+	return nil
+}
+
+// Нормализованные названия: триграммный индекс под LIKE '% слово %'
+// и заполнение name_norm у вакансий, записанных до его появления
+func migrateNameNorm() (err error) {
+	// расширение может быть недоступно без прав суперпользователя - тогда поиск работает без индекса
+	if err = DB.Socket.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		logger.Error(fmt.Sprintf("pg_trgm extension is not available, name_norm stays unindexed: %s", err))
+	} else if err = DB.Socket.Exec("CREATE INDEX IF NOT EXISTS idx_job_announces_name_norm_trgm ON job_announces USING gin (name_norm gin_trgm_ops)").Error; err != nil {
+		return fmt.Errorf("name_norm trigram index creating error: %w", err)
+	}
+
+	var batch JobAnnounces
+	err = DB.Socket.Select("item_id", "name").Where("(name_norm = '' or name_norm is null) and name <> ''").FindInBatches(&batch, jobAnnouncesBatchSize, func(_ *gorm.DB, _ int) error {
+		return DB.Socket.Transaction(func(tx *gorm.DB) error {
+			for _, a := range batch {
+				if err := tx.Model(&JobAnnounce{}).Where("item_id=?", a.ItemId).Update("name_norm", textnorm.Normalize(a.Name)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}).Error
+	if err != nil {
+		err = fmt.Errorf("name_norm backfill error: %w", err)
+	}
+	return
+}
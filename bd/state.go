@@ -0,0 +1,45 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// Запись состояния, прежнее состояние пользователя заменяется
+func SaveUserState(ctx context.Context, s UserState) (err error) {
+	onConflict := clause.OnConflict{Columns: []clause.Column{{Name: "tg_id"}}, DoUpdates: clause.AssignmentColumns([]string{"state", "sub_id", "item_id", "updated_at"})}
+	if err = DB.Socket.WithContext(ctx).Clauses(onConflict).Create(&s).Error; err != nil {
+		err = fmt.Errorf("user %d state saving error: %w", s.TgID, err)
+	}
+	return
+}
+
+// Состояние пользователя с удалением - одно сообщение забирает его ровно один раз.
+// Состояния, записанные раньше notBefore, истекли и не возвращаются
+func TakeUserState(ctx context.Context, tgID int64, notBefore time.Time) (s UserState, ok bool, err error) {
+	var taken []UserState
+	if err = DB.Socket.WithContext(ctx).Clauses(clause.Returning{}).Where("tg_id = ? and updated_at >= ?", tgID, notBefore).Delete(&taken).Error; err != nil {
+		err = fmt.Errorf("user %d state taking error: %w", tgID, err)
+		return
+	}
+	if len(taken) == 0 {
+		return s, false, nil
+	}
+	return taken[0], true, nil
+}
+
+// Удаление истекших состояний, возвращает их владельцев
+func TakeExpiredUserStates(ctx context.Context, before time.Time) (tgIDs []int64, err error) {
+	var taken []UserState
+	if err = DB.Socket.WithContext(ctx).Clauses(clause.Returning{Columns: []clause.Column{{Name: "tg_id"}}}).Where("updated_at < ?", before).Delete(&taken).Error; err != nil {
+		err = fmt.Errorf("expired user states taking error: %w", err)
+		return
+	}
+	for _, s := range taken {
+		tgIDs = append(tgIDs, s.TgID)
+	}
+	return
+}
@@ -0,0 +1,276 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vacancydealer/querylang"
+	"vacancydealer/textnorm"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultSubscriptionName = "Основной поиск"
+	incomeTaxRate           = 0.13 // НДФЛ, для сравнения "грязных" и "чистых" зарплат
+
+	// фраза запроса в русской и английской морфологии
+	tsQuerySQL = "(phraseto_tsquery('russian', ?) || phraseto_tsquery('english', ?))"
+
+	// верхняя граница вилки в рублях, NULL для валюты без курса
+	salaryInBaseCurrencySQL = "greatest(job_announces.salary_from, job_announces.salary_to) / nullif((select rate from currencies where currencies.code = job_announces.salary_currency), 0)"
+)
+
+// Условие языка запросов на поле вакансии.
+// Название ищется полнотекстово по части A search_vector либо по нормализованным словам name_norm -
+// для русских написаний технологий. Описание - по части B (сниппет из выдачи) и полному описанию.
+// search_vector @@ отбирает по индексу, ts_filter уточняет поле
+func matchQueryTerm(field querylang.Field, text string) (string, []any) {
+	pattern := "%" + strings.ToLower(text) + "%"
+	switch field {
+	case querylang.FieldDescription:
+		if !tsQueryable(text) {
+			return "(LOWER(description) like ? or LOWER(requirement) like ? or LOWER(responsebility) like ?)", []any{pattern, pattern, pattern}
+		}
+		return "((search_vector @@ " + tsQuerySQL + " and ts_filter(search_vector, '{b}') @@ " + tsQuerySQL + ") or LOWER(description) like ?)", []any{text, text, text, text, pattern}
+	case querylang.FieldCompany:
+		return "LOWER(company) like ?", []any{pattern}
+	}
+	if !tsQueryable(text) {
+		return "name_norm like ?", []any{"%" + textnorm.Normalize(text) + "%"}
+	}
+	return "((search_vector @@ " + tsQuerySQL + " and ts_filter(search_vector, '{a}') @@ " + tsQuerySQL + ") or name_norm like ?)", []any{text, text, text, text, "%" + textnorm.Normalize(text) + "%"}
+}
+
+// Парсер полнотекстового поиска отбрасывает "+" и "#": "C++" и "C#" превращаются в "c"
+// и находят лишнее. Такие слова ищутся только по словам текста
+func tsQueryable(text string) bool {
+	return !strings.ContainsAny(text, "+#")
+}
+
+// Сортировка по релевантности запросу, затем по зарплате
+func relevanceOrder(q querylang.Query) clause.OrderBy {
+	if q.Empty() {
+		return clause.OrderBy{Expression: clause.Expr{SQL: salaryInBaseCurrencySQL + " desc nulls last"}}
+	}
+	// любое из слов запроса повышает ранг, слова названия весят больше сниппета
+	terms := strings.Join(q.Terms(), " or ")
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:  "ts_rank(search_vector, websearch_to_tsquery('russian', ?) || websearch_to_tsquery('english', ?)) desc, " + salaryInBaseCurrencySQL + " desc nulls last",
+		Vars: []any{terms, terms},
+	}}
+}
+
+// Перенос фильтра из UserData в подписку для пользователей, у которых подписок еще нет
+func migrateUserFiltersToSubscriptions() (err error) {
+	var users UserDataList
+	if err = DB.Socket.Where("tg_id not in (?)", DB.Socket.Model(&Subscription{}).Select("tg_id")).Find(&users).Error; err != nil {
+		err = fmt.Errorf("users without subscriptions getting error: %w", err)
+		return
+	}
+
+	for _, u := range users {
+		s := Subscription{TgID: u.TgID, Name: DefaultSubscriptionName, Active: true, VacancyName: u.VacancyName, ExperienceYear: u.ExperienceYear, Schedule: u.Schedule, Location: u.Location}
+		if err = DB.Socket.Create(&s).Error; err != nil {
+			err = fmt.Errorf("user %d filter to subscription migration error: %w", u.TgID, err)
+			return
+		}
+	}
+	return nil
+}
+
+// Все подписки пользователя в порядке создания
+func GetUserSubscriptions(tgID int64) (subs Subscriptions, err error) {
+	if err = DB.Socket.Where("tg_id=?", tgID).Order("id").Find(&subs).Error; err != nil {
+		err = fmt.Errorf("user %d subscriptions getting error: %w", tgID, err)
+	}
+	return
+}
+
+// Включенные подписки пользователей, не приостановивших рассылку и закончивших знакомство с ботом
+func GetActiveSubscriptions(ctx context.Context) (subs Subscriptions, err error) {
+	db := DB.Socket.WithContext(ctx)
+	if err = db.Where("active and tg_id in (?)", db.Model(&UserData{}).Select("tg_id").Where("active and onboarding_step = 0")).Order("tg_id, id").Find(&subs).Error; err != nil {
+		err = fmt.Errorf("active subscriptions getting error: %w", err)
+	}
+	return
+}
+
+// Подписка пользователя по ИД. Чужая подписка не находится
+func FindSubscription(tgID int64, id uint) (s Subscription, err error) {
+	if err = DB.Socket.Where("tg_id=? and id=?", tgID, id).First(&s).Error; err != nil {
+		err = fmt.Errorf("subscription %d of user %d finding error: %w", id, tgID, err)
+	}
+	return
+}
+
+func CreateSubscription(tgID int64, name string) (s Subscription, err error) {
+	s = Subscription{TgID: tgID, Name: name, Active: true, Schedule: "fullDay", SalaryCurrency: "RUR"}
+	if err = DB.Socket.Create(&s).Error; err != nil {
+		err = fmt.Errorf("subscription creating error: %w", err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return
+}
+
+// Обновление фильтра подписки: профессия, опыт, график, локация, зарплата
+func (s Subscription) UpdateFilter() (err error) {
+	if err = DB.Socket.Model(&s).Select("vacancy_name", "experience_year", "schedule", "location", "salary_min", "salary_currency", "salary_gross", "only_with_salary").Updates(s).Error; err != nil {
+		err = fmt.Errorf("subscription %d filter updating error: %w", s.ID, err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return nil
+}
+
+func (s Subscription) Rename(name string) (err error) {
+	if err = DB.Socket.Model(&s).Update("name", name).Error; err != nil {
+		err = fmt.Errorf("subscription %d renaming error: %w", s.ID, err)
+	}
+	return
+}
+
+// Включение/выключение рассылки по подписке
+func (s Subscription) SetActive(active bool) (err error) {
+	if err = DB.Socket.Model(&s).Update("active", active).Error; err != nil {
+		err = fmt.Errorf("subscription %d activity updating error: %w", s.ID, err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return nil
+}
+
+func DeleteSubscription(tgID int64, id uint) (err error) {
+	if err = DB.Socket.Where("tg_id=? and id=?", tgID, id).Delete(&Subscription{}).Error; err != nil {
+		err = fmt.Errorf("subscription %d of user %d deleting error: %w", id, tgID, err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return nil
+}
+
+// Шаблоны поиска по профессиям из подписок
+func (subs Subscriptions) MakeVacNameSearchPatternPOOL() VacancyNamePatterns {
+	ud := make(UserDataList, 0, len(subs))
+	for _, s := range subs {
+		ud = append(ud, UserData{TgID: s.TgID, VacancyName: s.VacancyName})
+	}
+	return ud.MakeVacNameSearchPatternPOOL()
+}
+
+// Новые для пользователя вакансии по фильтру подписки
+func (s Subscription) GetJobAnnounces(ctx context.Context, areas Countries) (announces JobAnnounces, err error) {
+	query, vacancyQuery, err := s.matchQuery(ctx, areas)
+	if err != nil {
+		return
+	}
+
+	// уже доставленные пользователю, неудачные отправки предлагаются снова до deliveryMaxAttempts попыток
+	query = query.Where("item_id not in (?)", DB.Socket.WithContext(ctx).Model(&UserPivotVacancy{}).Select("job_id").Where("uid = ? and (status <> ? or attempts >= ?)", s.TgID, DeliveryFailed, deliveryMaxAttempts))
+
+	if err = query.Limit(50).Order(relevanceOrder(vacancyQuery)).Find(&announces).Error; err != nil {
+		err = fmt.Errorf("db vacancy with param schedule getting error: %w", err)
+		return
+	}
+
+	return
+}
+
+// Страница всех подходящих под фильтр подписки вакансий, включая показанные, и их общее число.
+// Порядок - по релевантности, затем по ИД: страницы не перемешиваются между запросами
+func (s Subscription) BrowseJobAnnounces(ctx context.Context, areas Countries, offset, limit int) (announces JobAnnounces, total int64, err error) {
+	query, vacancyQuery, err := s.matchQuery(ctx, areas)
+	if err != nil {
+		return
+	}
+
+	if err = query.Model(&JobAnnounce{}).Count(&total).Error; err != nil {
+		err = fmt.Errorf("subscription %d vacancies counting error: %w", s.ID, err)
+		return
+	}
+	if err = query.Order(relevanceOrder(vacancyQuery)).Order("item_id").Offset(offset).Limit(limit).Find(&announces).Error; err != nil {
+		err = fmt.Errorf("subscription %d vacancies browsing error: %w", s.ID, err)
+	}
+	return
+}
+
+// Код опыта работы ХэХа по стажу в годах: так опыт хранится у вакансий и передается в поиск ХэХа
+func ExperienceCode(years int) string {
+	switch {
+	case years < 1:
+		return "noExperience"
+	case years <= 3:
+		return "between1And3"
+	case years <= 6:
+		return "between3And6"
+	}
+	return "moreThan6"
+}
+
+// Вакансии по фильтру подписки: запрос, опыт, график, локация, зарплата и исключения пользователя.
+// Запрос можно продолжать несколько раз
+func (s Subscription) matchQuery(ctx context.Context, areas Countries) (query *gorm.DB, vacancyQuery querylang.Query, err error) {
+	db := DB.Socket.WithContext(ctx)
+
+	expierence := ExperienceCode(s.ExperienceYear)
+
+	if vacancyQuery, err = querylang.Parse(s.VacancyName); err != nil {
+		err = fmt.Errorf("subscription %d query parsing error: %w", s.ID, err)
+		return
+	}
+	predicate, args := vacancyQuery.SQL(matchQueryTerm)
+
+	// у вакансий из лент опыт, график и локация обычно не известны - такие не отсеиваются
+	query = db.Where("canonical_id = 0 and (expierence = ? or expierence = '') and (schedule = ? or schedule = '')", expierence, s.Schedule).Where(predicate, args...)
+	if locationsTarget := areas.FindContainLocationIDsList(s.Location); len(locationsTarget) != 0 {
+		query = query.Where("(area in ? or area = 0)", locationsTarget)
+	}
+	cur, err := GetCurrencies()
+	if err != nil {
+		return
+	}
+	ex, err := GetUserExclusions(s.TgID)
+	if err != nil {
+		return
+	}
+	query = ex.condition(s.salaryCondition(query, cur)).Session(&gorm.Session{})
+
+	return
+}
+
+// Отбор по зарплате: верхняя граница вилки, приведенная к "грязной"/"чистой" сумме как у пользователя
+// и пересчитанная в рубли, не ниже желаемой. Вакансии без зарплаты проходят,
+// если пользователю не нужна только указанная ЗП
+func (s Subscription) salaryCondition(query *gorm.DB, cur Currencies) *gorm.DB {
+	if s.OnlyWithSalary {
+		query = query.Where("(salary_from > 0 or salary_to > 0)")
+	}
+	if s.SalaryMin <= 0 {
+		return query
+	}
+
+	grossK, netK := 1-incomeTaxRate, 1.0
+	if s.SalaryGross {
+		grossK, netK = 1, 1/(1-incomeTaxRate)
+	}
+
+	// сравнение в валюте пользователя: справочник валют еще не загружен или у валюты вакансии нет курса
+	sameCurrency := "(salary_currency = ? and greatest(salary_from, salary_to) * (case when salary_gross then ? else ? end) >= ?)"
+
+	// Convert из RUR в RUR успешен и без справочника, а SQL делит на курс из таблицы - без курса рубля там NULL
+	minSalary, ok := cur.Convert(float64(s.SalaryMin), s.SalaryCurrency, BaseCurrency)
+	if _, baseOK := cur.rate(BaseCurrency); !ok || !baseOK {
+		return query.Where("((salary_from = 0 and salary_to = 0 and not ?) or "+sameCurrency+")", s.OnlyWithSalary, s.SalaryCurrency, grossK, netK, s.SalaryMin)
+	}
+	return query.Where("((salary_from = 0 and salary_to = 0 and not ?) or coalesce("+salaryInBaseCurrencySQL+" * (case when salary_gross then ? else ? end) >= ?, "+sameCurrency+"))", s.OnlyWithSalary, grossK, netK, minSalary, s.SalaryCurrency, grossK, netK, s.SalaryMin)
+}
@@ -0,0 +1,139 @@
+package bd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // база часовых поясов внутри бинарника: в контейнере ее может не быть
+)
+
+// Часовой пояс, если его не удалось определить по локации
+const DefaultTimezone = "Europe/Moscow"
+
+var (
+	// часовые пояса стран по названию из дерева локаций ХэХа
+	countryTimezones = map[string]string{
+		"Россия":       "Europe/Moscow",
+		"Беларусь":     "Europe/Minsk",
+		"Украина":      "Europe/Kyiv",
+		"Казахстан":    "Asia/Almaty",
+		"Узбекистан":   "Asia/Tashkent",
+		"Кыргызстан":   "Asia/Bishkek",
+		"Таджикистан":  "Asia/Dushanbe",
+		"Туркменистан": "Asia/Ashgabat",
+		"Азербайджан":  "Asia/Baku",
+		"Армения":      "Asia/Yerevan",
+		"Грузия":       "Asia/Tbilisi",
+		"Молдова":      "Europe/Chisinau",
+	}
+
+	// регионы России не по московскому времени, по началу названия
+	regionTimezones = map[string]string{
+		"Калининградская":         "Europe/Kaliningrad",
+		"Самарская":               "Europe/Samara",
+		"Удмуртская":              "Europe/Samara",
+		"Ульяновская":             "Europe/Ulyanovsk",
+		"Астраханская":            "Europe/Astrakhan",
+		"Саратовская":             "Europe/Saratov",
+		"Свердловская":            "Asia/Yekaterinburg",
+		"Челябинская":             "Asia/Yekaterinburg",
+		"Тюменская":               "Asia/Yekaterinburg",
+		"Курганская":              "Asia/Yekaterinburg",
+		"Оренбургская":            "Asia/Yekaterinburg",
+		"Пермский":                "Asia/Yekaterinburg",
+		"Республика Башкортостан": "Asia/Yekaterinburg",
+		"Ханты-Мансийский":        "Asia/Yekaterinburg",
+		"Ямало-Ненецкий":          "Asia/Yekaterinburg",
+		"Омская":                  "Asia/Omsk",
+		"Новосибирская":           "Asia/Novosibirsk",
+		"Томская":                 "Asia/Tomsk",
+		"Алтайский":               "Asia/Barnaul",
+		"Республика Алтай":        "Asia/Barnaul",
+		"Кемеровская":             "Asia/Novokuznetsk",
+		"Красноярский":            "Asia/Krasnoyarsk",
+		"Республика Хакасия":      "Asia/Krasnoyarsk",
+		"Республика Тыва":         "Asia/Krasnoyarsk",
+		"Иркутская":               "Asia/Irkutsk",
+		"Республика Бурятия":      "Asia/Irkutsk",
+		"Забайкальский":           "Asia/Chita",
+		"Амурская":                "Asia/Yakutsk",
+		"Республика Саха":         "Asia/Yakutsk",
+		"Приморский":              "Asia/Vladivostok",
+		"Хабаровский":             "Asia/Vladivostok",
+		"Еврейская":               "Asia/Vladivostok",
+		"Магаданская":             "Asia/Magadan",
+		"Сахалинская":             "Asia/Sakhalin",
+		"Камчатский":              "Asia/Kamchatka",
+		"Чукотский":               "Asia/Anadyr",
+	}
+)
+
+// Часовой пояс локации: по региону, затем по стране
+func (ad Countries) Timezone(areaID uint) string {
+	country, region, _ := ad.FindLocationByAreaID(int(areaID))
+	if region != nil {
+		for prefix, tz := range regionTimezones {
+			if strings.HasPrefix(region.Name, prefix) {
+				return tz
+			}
+		}
+	}
+	if country != nil {
+		if tz, ok := countryTimezones[country.Name]; ok {
+			return tz
+		}
+	}
+	return DefaultTimezone
+}
+
+// Часовой пояс пользователя: выбранный им, иначе по локации первой подписки с локацией
+func (u UserData) TimeLocation(subs Subscriptions, areas Countries) *time.Location {
+	tz := u.Timezone
+	if tz == "" {
+		tz = DefaultTimezone
+		for _, s := range subs {
+			if s.Location != 0 {
+				tz = areas.Timezone(s.Location)
+				break
+			}
+		}
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc, _ = time.LoadLocation(DefaultTimezone)
+	}
+	return loc
+}
+
+// Тихие часы по местному времени now. Начало равное концу - тихих часов нет
+func (u UserData) InQuietHours(now time.Time) bool {
+	if u.QuietFrom == u.QuietTo {
+		return false
+	}
+	h := now.Hour()
+	if u.QuietFrom < u.QuietTo {
+		return h >= u.QuietFrom && h < u.QuietTo
+	}
+	return h >= u.QuietFrom || h < u.QuietTo
+}
+
+// Выбор часового пояса, пустой - определять по локации
+func (u UserData) SetTimezone(tz string) (err error) {
+	if tz != "" {
+		if _, err = time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("timezone %q loading error: %w", tz, err)
+		}
+	}
+	if err = DB.Socket.Model(&UserData{}).Where("tg_id=?", u.TgID).Update("timezone", tz).Error; err != nil {
+		err = fmt.Errorf("user %d timezone updating error: %w", u.TgID, err)
+	}
+	return
+}
+
+func (u UserData) SetQuietHours(from, to int) (err error) {
+	if err = DB.Socket.Model(&UserData{}).Where("tg_id=?", u.TgID).Updates(map[string]any{"quiet_from": from, "quiet_to": to}).Error; err != nil {
+		err = fmt.Errorf("user %d quiet hours updating error: %w", u.TgID, err)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package bd
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Приостановка и возобновление всей рассылки пользователя, подписки не меняются
+func (u UserData) SetActive(active bool) (err error) {
+	if err = DB.Socket.Model(&UserData{}).Where("tg_id=?", u.TgID).Update("active", active).Error; err != nil {
+		err = fmt.Errorf("user %d activity updating error: %w", u.TgID, err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return nil
+}
+
+func (u UserData) SetOnboardingStep(step uint8) (err error) {
+	if err = DB.Socket.Model(&UserData{}).Where("tg_id=?", u.TgID).Update("onboarding_step", step).Error; err != nil {
+		err = fmt.Errorf("user %d onboarding step updating error: %w", u.TgID, err)
+	}
+	return
+}
+
+// Удаление всех данных пользователя: подписки, исключения, доставки, избранное, состояние диалога
+func DeleteUserData(ctx context.Context, tgID int64) (err error) {
+	err = DB.Socket.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+		for _, del := range []*gorm.DB{
+			tx.Where("tg_id=?", tgID).Delete(&Subscription{}),
+			tx.Where("tg_id=?", tgID).Delete(&BlockedEmployer{}),
+			tx.Where("uid=?", tgID).Delete(&UserPivotVacancy{}),
+			tx.Where("tg_id=?", tgID).Delete(&SavedVacancy{}),
+			tx.Where("tg_id=?", tgID).Delete(&UserState{}),
+			tx.Where("tg_id=?", tgID).Delete(&UserData{}),
+		} {
+			if del.Error != nil {
+				return del.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("user %d data deleting error: %w", tgID, err)
+		return
+	}
+
+	notifyWorkDue()
+
+	return nil
+}
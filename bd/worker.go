@@ -1,17 +1,28 @@
 package bd
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"vacancydealer/logger"
+	"vacancydealer/querylang"
 )
 
 var (
-	WorkDue = make(chan bool)
+	// Сигнал пересборки шаблонов поиска. Буфер на один сигнал: повторные до обработки не нужны
+	WorkDue = make(chan bool, 1)
 )
 
-func GetAllUserData() (ud UserDataList, err error) {
-	if err = DB.Socket.Find(&ud).Error; err != nil {
+// Неблокирующая отправка сигнала пересборки шаблонов
+func notifyWorkDue() {
+	select {
+	case WorkDue <- true:
+	default:
+	}
+}
+
+func GetAllUserData(ctx context.Context) (ud UserDataList, err error) {
+	if err = DB.Socket.WithContext(ctx).Find(&ud).Error; err != nil {
 		err = fmt.Errorf("al user data getting error: %w", err)
 	}
 	return
@@ -19,7 +30,14 @@ func GetAllUserData() (ud UserDataList, err error) {
 
 func (ud UserDataList) MakeVacNameSearchPatternPOOL() (searchKeys VacancyNamePatterns) {
 	tempNameList := make(map[string]bool, len(ud))
+	// запросы с OR, NOT и полями не объединяются по вхождению строки:
+	// "golang OR rust" не сводится к "rust", а "golang NOT 1С" - к "1С"
+	standalone := make(map[string]bool)
 	for _, d := range ud {
+		if q, err := querylang.Parse(d.VacancyName); err == nil && !q.Conjunctive() {
+			standalone[d.VacancyName] = true
+			continue
+		}
 		if _, ok := tempNameList[d.VacancyName]; !ok {
 			tempNameList[d.VacancyName] = true
 		} else {
@@ -42,6 +60,10 @@ func (ud UserDataList) MakeVacNameSearchPatternPOOL() (searchKeys VacancyNamePat
 		}
 	}
 
+	for k := range standalone {
+		tempNameList[k] = true
+	}
+
 	var sqlVacID uint = 1
 	for k, v := range tempNameList {
 		if v {
@@ -54,22 +76,32 @@ func (ud UserDataList) MakeVacNameSearchPatternPOOL() (searchKeys VacancyNamePat
 }
 
 func (searchKeys VacancyNamePatterns) SaveInDB() (err error) {
+	// после удаления подписок пул может сократиться - лишние шаблоны больше не выгружаются
+	if err = DB.Socket.Where("id > ?", len(searchKeys)).Delete(&VacancynameSearchPattern{}).Error; err != nil {
+		err = fmt.Errorf("stale vacancy name patterns deleting error: %w", err)
+		return
+	}
+	if len(searchKeys) == 0 {
+		return nil
+	}
 	if err = DB.Socket.Save(&searchKeys).Error; err != nil {
 		err = fmt.Errorf("vacancy name pool in database saving error: %w", err)
 	}
 	return
 }
 
-func StarWorker(ch <-chan bool) {
+func StarWorker(ctx context.Context, ch <-chan bool) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-ch:
-			ud, err := GetAllUserData()
+			subs, err := GetActiveSubscriptions(ctx)
 			if err != nil {
 				logger.Error(err.Error())
 				continue
 			}
-			if err = ud.MakeVacNameSearchPatternPOOL().SaveInDB(); err != nil {
+			if err = subs.MakeVacNameSearchPatternPOOL().SaveInDB(); err != nil {
 				logger.Error(err.Error())
 				continue
 			}
@@ -4,19 +4,62 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	defaultResyncGap      = 24 * time.Hour
+	defaultHTTPTimeoutSec = 30
+	defaultHTTPRPS        = 5
+	defaultHTTPMaxRetries = 4
+
+	defaultShutdownTimeout = 20 * time.Second
+)
+
 type (
 	Configs struct {
+		// время на остановку воркеров после SIGINT/SIGTERM
+		Shutdown time.Duration `env:"SHUTDOWN_TIMEOUT_SEC"`
+
 		DMS  *DataBase
 		Tbot *TbotData
+		HH   *HHdata
+		SJ   *SJdata
+		RSS  *RSSdata
+		HTTP *HTTPdata
 	}
 	TbotData struct {
 		API string `env:"TGBOT_APIKEY"`
 	}
 
+	HHdata struct {
+		ResyncGap time.Duration `env:"HH_RESYNC_GAP_HOURS"`
+	}
+
+	SJdata struct {
+		APIKey string `env:"SJ_APIKEY"`
+	}
+
+	// RSS_FEEDS: ленты через запятую, у ленты можно указать компанию: url|Компания
+	RSSdata struct {
+		Feeds []FeedData `env:"RSS_FEEDS"`
+	}
+	FeedData struct {
+		URL     string
+		Company string
+	}
+
+	// общий http-клиент источников: таймаут, частота запросов в секунду, повторы
+	HTTPdata struct {
+		Timeout    time.Duration `env:"HTTP_TIMEOUT_SEC"`
+		RPS        int           `env:"HTTP_RPS"`
+		Burst      int           `env:"HTTP_BURST"`
+		MaxRetries int           `env:"HTTP_MAX_RETRIES"`
+	}
+
 	DataBase struct {
 		Host     string `env:"DB_HOST"`
 		Port     int    `env:"DB_PORT"`
@@ -32,7 +75,7 @@ func LoadConfig() (c Configs, err error) {
 		err = fmt.Errorf("config loading -> env-file loading error: %w", err)
 		return
 	}
-	c = Configs{&DataBase{Host: os.Getenv("DB_HOST"), DBname: os.Getenv("DB_NAME"), User: os.Getenv("DB_USER"), Password: os.Getenv("DB_PASSWORD"), SSLmode: os.Getenv("DB_SSLMODE")}, &TbotData{API: os.Getenv("TGBOT_APIKEY")}}
+	c = Configs{defaultShutdownTimeout, &DataBase{Host: os.Getenv("DB_HOST"), DBname: os.Getenv("DB_NAME"), User: os.Getenv("DB_USER"), Password: os.Getenv("DB_PASSWORD"), SSLmode: os.Getenv("DB_SSLMODE")}, &TbotData{API: os.Getenv("TGBOT_APIKEY")}, &HHdata{ResyncGap: defaultResyncGap}, &SJdata{APIKey: os.Getenv("SJ_APIKEY")}, &RSSdata{}, &HTTPdata{}}
 
 	if dbport, err := strconv.Atoi(os.Getenv("DB_PORT")); err != nil {
 		err = fmt.Errorf("config field DB_PORT parse error: %w", err)
@@ -41,5 +84,52 @@ func LoadConfig() (c Configs, err error) {
 		c.DMS.Port = dbport
 	}
 
+	hours, err := envInt("HH_RESYNC_GAP_HOURS", int(defaultResyncGap/time.Hour))
+	if err != nil {
+		return Configs{}, err
+	}
+	c.HH.ResyncGap = time.Duration(hours) * time.Hour
+
+	timeout, err := envInt("HTTP_TIMEOUT_SEC", defaultHTTPTimeoutSec)
+	if err != nil {
+		return Configs{}, err
+	}
+	c.HTTP.Timeout = time.Duration(timeout) * time.Second
+	if c.HTTP.RPS, err = envInt("HTTP_RPS", defaultHTTPRPS); err != nil {
+		return Configs{}, err
+	}
+	if c.HTTP.Burst, err = envInt("HTTP_BURST", defaultHTTPRPS); err != nil {
+		return Configs{}, err
+	}
+	if c.HTTP.MaxRetries, err = envInt("HTTP_MAX_RETRIES", defaultHTTPMaxRetries); err != nil {
+		return Configs{}, err
+	}
+
+	shutdown, err := envInt("SHUTDOWN_TIMEOUT_SEC", int(defaultShutdownTimeout/time.Second))
+	if err != nil {
+		return Configs{}, err
+	}
+	c.Shutdown = time.Duration(shutdown) * time.Second
+
+	for _, feed := range strings.Split(os.Getenv("RSS_FEEDS"), ",") {
+		url, company, _ := strings.Cut(strings.TrimSpace(feed), "|")
+		if url != "" {
+			c.RSS.Feeds = append(c.RSS.Feeds, FeedData{URL: url, Company: strings.TrimSpace(company)})
+		}
+	}
+
 	return
 }
+
+// Необязательное целочисленное поле конфига
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("config field %s parse error: %w", name, err)
+	}
+	return i, nil
+}
@@ -5,6 +5,7 @@ go 1.23.2
 require (
 	github.com/go-telegram/bot v1.8.4
 	github.com/joho/godotenv v1.5.1
+	golang.org/x/text v0.14.0
 	gorm.io/driver/postgres v1.5.9
 	gorm.io/gorm v1.25.12
 )
@@ -18,5 +19,4 @@ require (
 	github.com/jinzhu/now v1.1.5 // indirect
 	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
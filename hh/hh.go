@@ -1,15 +1,19 @@
 package hh
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"vacancydealer/bd"
 	"vacancydealer/htpcli"
+	"vacancydealer/querylang"
+	"vacancydealer/vacsource"
 )
 
 type (
@@ -17,16 +21,19 @@ type (
 	schedule   string
 )
 
+const SourceName = "hh"
+
 var (
 	StatusBadRequest = errors.New("status BadRequest")
+	StatusNotFound   = errors.New("status NotFound")
 )
 
 // Инициализация базовых справочников из ХэХа
 // Получение данных Локаций
 // Получение графиков работ
 // Запись в БД
-func Init() (err error) {
-	areasHH, err := getAreas()
+func Init(ctx context.Context) (err error) {
+	areasHH, err := getAreas(ctx)
 	if err != nil {
 		return
 	}
@@ -35,26 +42,33 @@ func Init() (err error) {
 		return
 	}
 
-	schedulesHH, err := GetSchedulesList()
+	schedulesHH, err := GetSchedulesList(ctx)
 	if err != nil {
 		return
 	}
 	if err = schedulesHH.SchedulesModelConvert().CreateToDB(); err != nil {
 		return
 	}
+	if err = schedulesHH.CurrenciesModelConvert().SaveInDB(); err != nil {
+		return
+	}
 
 	return nil
 }
 
 // sent query to HH
-func (dataFilter UserFilter) GetVacancies(pp, page int) (rsp HHresponse, err error) {
-	var hh htpcli.RequestDealer = &htpcli.HTTPclient{Socket: &http.Client{}}
+func (dataFilter UserFilter) GetVacancies(ctx context.Context, pp, page int) (rsp HHresponse, err error) {
+	var hh htpcli.RequestDealer = htpcli.New()
 	urq := fmt.Sprintf("https://api.hh.ru/vacancies?&experience=%s&schedule=%s&applicant_comments_order=creation_time_desc&per_page=%d", dataFilter.Experience, dataFilter.Schedule, pp)
 	if dataFilter.Vacancyname != "" {
-		if strings.Contains(dataFilter.Vacancyname, " ") {
-			dataFilter.Vacancyname = strings.ReplaceAll(dataFilter.Vacancyname, " ", "+")
+		text, err := queryText(dataFilter.Vacancyname)
+		if err != nil {
+			return rsp, err
 		}
-		urq += "&text=NAME%3A(" + dataFilter.Vacancyname + ")"
+		if len(dataFilter.ExcludeWords) != 0 {
+			text += " NOT (" + excludeWordsText(dataFilter.ExcludeWords) + ")"
+		}
+		urq += "&text=" + url.QueryEscape(text)
 	}
 
 	if page != 0 {
@@ -63,16 +77,31 @@ func (dataFilter UserFilter) GetVacancies(pp, page int) (rsp HHresponse, err err
 	if dataFilter.Location != 0 {
 		urq += "&area=" + strconv.Itoa(dataFilter.Location)
 	}
+	if dataFilter.Salary > 0 {
+		urq += "&salary=" + strconv.Itoa(dataFilter.Salary)
+		if dataFilter.Currency != "" {
+			urq += "&currency=" + url.QueryEscape(dataFilter.Currency)
+		}
+	}
+	if dataFilter.OnlyWithSalary {
+		urq += "&only_with_salary=true"
+	}
 
-	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do()
+	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do(ctx)
 	if err != nil {
 		return
 	}
 
+	defer r.Body.Close()
+
 	switch r.StatusCode {
+	case http.StatusOK:
 	case http.StatusBadRequest:
 		err = StatusBadRequest
 		return
+	default:
+		err = fmt.Errorf("hh vacancies response status %d", r.StatusCode)
+		return
 	}
 
 	b, err := io.ReadAll(r.Body)
@@ -86,15 +115,75 @@ func (dataFilter UserFilter) GetVacancies(pp, page int) (rsp HHresponse, err err
 	return
 }
 
+// Запрос пользователя на языке запросов ХэХа
+func queryText(vacancyName string) (text string, err error) {
+	q, err := querylang.Parse(vacancyName)
+	if err != nil {
+		err = fmt.Errorf("hh query %q compile error: %w", vacancyName, err)
+		return
+	}
+	return q.HHText(), nil
+}
+
+// Стоп-слова на языке запросов ХэХа: фразы в кавычках, через OR
+func excludeWordsText(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		quoted = append(quoted, strconv.Quote(w))
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// query to HH API
+// Вакансия целиком
+func getVacancy(ctx context.Context, id string) (rsp HHitem, err error) {
+	var hh htpcli.RequestDealer = htpcli.New()
+	urq := "https://api.hh.ru/vacancies/" + url.PathEscape(id)
+	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do(ctx)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusNotFound:
+		err = StatusNotFound
+		return
+	case http.StatusBadRequest:
+		err = StatusBadRequest
+		return
+	case http.StatusOK:
+	default:
+		// пустая карточка иначе записалась бы как загруженная и выпала из очереди
+		err = fmt.Errorf("hh %s response status %d", urq, r.StatusCode)
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &rsp); err != nil {
+		err = fmt.Errorf("hh vacancy %s unmarshal error: %w", id, err)
+	}
+	return
+}
+
 // query to HH API
 // Получаем локации от ХэХа
-func getAreas() (rsp Areas, err error) {
-	var hh htpcli.RequestDealer = &htpcli.HTTPclient{Socket: &http.Client{}}
+func getAreas(ctx context.Context) (rsp Areas, err error) {
+	var hh htpcli.RequestDealer = htpcli.New()
 	urq := "https://api.hh.ru/areas"
-	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do()
+	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do(ctx)
 	if err != nil {
 		return
 	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		err = fmt.Errorf("hh %s response status %d", urq, r.StatusCode)
+		return
+	}
 
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -107,13 +196,19 @@ func getAreas() (rsp Areas, err error) {
 }
 
 // query to HH API
-func GetSchedulesList() (rsp ScheduleData, err error) {
-	var hh htpcli.RequestDealer = &htpcli.HTTPclient{Socket: &http.Client{}}
+func GetSchedulesList(ctx context.Context) (rsp ScheduleData, err error) {
+	var hh htpcli.RequestDealer = htpcli.New()
 	urq := "https://api.hh.ru/dictionaries"
-	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do()
+	r, err := hh.NewGet(urq, map[string]string{"User-Agent": "HH-User-Agent"}).Do(ctx)
 	if err != nil {
 		return
 	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		err = fmt.Errorf("hh %s response status %d", urq, r.StatusCode)
+		return
+	}
 
 	b, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -196,20 +291,17 @@ func (from ScheduleData) SchedulesModelConvert() (to bd.Schedules) {
 	return
 }
 
-// user convert model data of users from package bd to models of UserFilter
-func ConvertUserData(userdata []bd.UserData) (userFilterList []UserFilter) {
-	for _, bdUd := range userdata {
-		userFilterTemp := UserFilter{TgID: bdUd.TgID, Vacancyname: bdUd.VacancyName, Location: int(bdUd.Location), Schedule: bdUd.Schedule}
-		if bdUd.ExperienceYear < 1 {
-			userFilterTemp.Experience = "noExperience"
-		} else if bdUd.ExperienceYear > 0 && bdUd.ExperienceYear < 4 {
-			userFilterTemp.Experience = "between1And3"
-		} else if bdUd.ExperienceYear > 3 && bdUd.ExperienceYear < 7 {
-			userFilterTemp.Experience = "between3And6"
-		} else if bdUd.ExperienceYear > 6 {
-			userFilterTemp.Experience = "moreThan6"
-		}
-		userFilterList = append(userFilterList, userFilterTemp)
+func (from ScheduleData) CurrenciesModelConvert() (to bd.Currencies) {
+	for _, c := range from.Currencies {
+		to = append(to, bd.Currency{Code: c.Code, Abbr: c.Abbr, Name: c.Name, Rate: c.Rate, InUse: c.InUse})
 	}
 	return
 }
+
+// common filter of package vacsource to model of UserFilter convert
+func ConvertFilter(filter vacsource.Filter) UserFilter {
+	// ХэХа не различает "грязную" и "чистую" зарплату в запросе - сумма передается как есть
+	userFilter := UserFilter{TgID: filter.TgID, Vacancyname: filter.VacancyName, Location: int(filter.Location), Schedule: filter.Schedule, Salary: filter.SalaryMin, Currency: filter.SalaryCurrency, OnlyWithSalary: filter.OnlyWithSalary, ExcludeWords: filter.Exclusions.Words}
+	userFilter.Experience = bd.ExperienceCode(filter.ExperienceYear)
+	return userFilter
+}
@@ -1,5 +1,10 @@
 package hh
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type (
 	// for hh vacancy annonce query
 	HHresponse struct {
@@ -20,6 +25,22 @@ type (
 		Employer    EmployerEntity   `json:"employer"`
 		Snippet     SnippetEntity    `json:"snippet"`
 		Schedule    ScheduleEntity   `json:"schedule"`
+
+		// только в полной карточке /vacancies/{id}
+		Description       string           `json:"description"`
+		KeySkills         []NamedEntity    `json:"key_skills"`
+		Employment        NamedEntity      `json:"employment"`
+		Address           *AddressEntity   `json:"address"`
+		Contacts          *json.RawMessage `json:"contacts"`
+		ProfessionalRoles []NamedEntity    `json:"professional_roles"`
+		Archived          bool             `json:"archived"`
+	}
+	NamedEntity struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	AddressEntity struct {
+		Raw string `json:"raw"`
 	}
 	TypeEntity struct {
 		ID string `json:"id"`
@@ -76,24 +97,43 @@ type (
 	Areas []Area
 
 	// for hh vacancy params
+	// ответ /dictionaries: нужны графики работы и валюты
 	ScheduleData struct {
-		List []Schedule `json:"schedule"`
+		List       []Schedule       `json:"schedule"`
+		Currencies []CurrencyEntity `json:"currency"`
 	}
 	Schedule struct {
 		Id   string `json:"id"`
 		Name string `json:"name"`
 	}
+	CurrencyEntity struct {
+		Code  string  `json:"code"`
+		Abbr  string  `json:"abbr"`
+		Name  string  `json:"name"`
+		Rate  float64 `json:"rate"`
+		InUse bool    `json:"in_use"`
+	}
 
 	//UserFilter data
 	UserFilter struct {
-		TgID        int64
-		Vacancyname string
-		Experience  string
-		Schedule    string
-		Location    int
+		TgID           int64
+		Vacancyname    string
+		Experience     string
+		Schedule       string
+		Location       int
+		Salary         int
+		Currency       string
+		OnlyWithSalary bool
+		ExcludeWords   []string
 	}
 
+	// Параметры одного запроса харвестера.
+	// Area, DateFrom и DateTo заполняются при дроблении запроса,
+	// если выдача упирается в лимит глубины ХэХа
 	HHfilterData struct {
 		VacancyName string
+		Area        uint
+		DateFrom    time.Time
+		DateTo      time.Time
 	}
 )
@@ -0,0 +1,63 @@
+package hh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/vacsource"
+)
+
+// hh.ru как источник вакансий
+type Source struct{}
+
+var _ vacsource.VacancySource = Source{}
+
+func (Source) Name() string {
+	return SourceName
+}
+
+func (Source) LoadDictionaries(ctx context.Context) error {
+	return Init(ctx)
+}
+
+func (Source) Search(ctx context.Context, filter vacsource.Filter, perPage, page int) (result vacsource.Page, err error) {
+	rsp, err := ConvertFilter(filter).GetVacancies(ctx, perPage, page)
+	if err != nil {
+		err = fmt.Errorf("hh search error: %w", err)
+		return
+	}
+	// работодателей ХэХа запросом не исключить - отсев по выдаче
+	return vacsource.Page{Found: rsp.Found, Pages: rsp.Pages, Items: filter.Exclude(rsp.ConvertItemsToDB(nil))}, nil
+}
+
+func (Source) Harvest(ctx context.Context, pattern string, dateFrom time.Time, areas bd.Countries) (items bd.JobAnnounces, err error) {
+	rsp, err := HHfilterData{VacancyName: pattern, DateFrom: dateFrom}.GetJobAnnounces(ctx, areas)
+	if err != nil {
+		err = fmt.Errorf("hh harvest of pattern %q error: %w", pattern, err)
+		return
+	}
+	return rsp.ConvertItemsToDB(areas), nil
+}
+
+func (Source) VacancyDetails(ctx context.Context, sourceID string) (announce bd.JobAnnounce, err error) {
+	item, err := getVacancy(ctx, sourceID)
+	if errors.Is(err, StatusNotFound) {
+		err = fmt.Errorf("hh vacancy %s details error: %w", sourceID, vacsource.ErrVacancyNotFound)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("hh vacancy %s details error: %w", sourceID, err)
+		return
+	}
+	items := HHresponse{Items: []HHitem{item}}.ConvertItemsToDB(nil)
+	if len(items) == 0 {
+		err = fmt.Errorf("hh vacancy %s details convert error", sourceID)
+		return
+	}
+
+	announce = items[0]
+	announce.VacancyDetails = item.convertDetails()
+	return announce, nil
+}
@@ -1,23 +1,28 @@
 package hh
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	"vacancydealer/bd"
 	"vacancydealer/htpcli"
 	"vacancydealer/logger"
+	"vacancydealer/vacsource"
 )
 
-func ConvertSerchPatternModelDBtoHH(from bd.VacancyNamePatterns) (to []HHfilterData) {
-	for _, v := range from {
-		to = append(to, HHfilterData{VacancyName: v.VacancyName})
-	}
-	return
-}
+const (
+	hhPerPage       = 100  // максимум элементов на странице выдачи
+	hhDepthLimit    = 2000 // ХэХ не отдает больше 2000 элементов на один запрос
+	hhSearchPeriod  = 30 * 24 * time.Hour
+	hhMinDateWindow = time.Hour // минимальный интервал дат, до которого дробится запрос
+	hhDateLayout    = "2006-01-02T15:04:05-0700"
+)
 
 func (hh HHresponse) ConvertItemsToDB(areas bd.Countries) (bdja bd.JobAnnounces) {
 	for _, vac := range hh.Items {
@@ -31,11 +36,32 @@ func (hh HHresponse) ConvertItemsToDB(areas bd.Countries) (bdja bd.JobAnnounces)
 			continue
 		}
 
-		bdja = append(bdja, bd.JobAnnounce{ItemId: uint(id), Name: vac.Name, Company: vac.Employer.Name, Area: locID, Expierence: vac.Experience.ID, SalaryGross: vac.Salary.Gross, SalaryFrom: vac.Salary.From, SalaryTo: vac.Salary.To, SalaryCurrency: vac.Salary.Currency, PublishedAt: vac.PublishedAt, Schedule: vac.Schedule.ID, Requirement: vac.Snippet.Requirement, Responsebility: vac.Snippet.Responsibility, Link: vac.PageURL})
+		bdja = append(bdja, bd.JobAnnounce{ItemId: uint(id), Source: SourceName, SourceID: vac.ID, Name: vac.Name, Company: vac.Employer.Name, EmployerTrusted: vac.Employer.Trusted, Area: locID, Expierence: vac.Experience.ID, SalaryGross: vac.Salary.Gross, SalaryFrom: vac.Salary.From, SalaryTo: vac.Salary.To, SalaryCurrency: vac.Salary.Currency, PublishedAt: vac.PublishedAt, Schedule: vac.Schedule.ID, Requirement: vac.Snippet.Requirement, Responsebility: vac.Snippet.Responsibility, Link: vac.PageURL})
 	}
 	return
 }
 
+// Подробности полной карточки вакансии to model of package bd convert
+func (vac HHitem) convertDetails() (details bd.VacancyDetails) {
+	details = bd.VacancyDetails{Description: vacsource.PlainText(vac.Description), Employment: vac.Employment.Name, HasContacts: vac.Contacts != nil, Archived: vac.Archived}
+	if vac.Address != nil {
+		details.Address = vac.Address.Raw
+	}
+
+	skills := make([]string, 0, len(vac.KeySkills))
+	for _, s := range vac.KeySkills {
+		skills = append(skills, s.Name)
+	}
+	details.KeySkills = strings.Join(skills, ", ")
+
+	roles := make([]string, 0, len(vac.ProfessionalRoles))
+	for _, r := range vac.ProfessionalRoles {
+		roles = append(roles, r.Name)
+	}
+	details.ProfessionalRoles = strings.Join(roles, ", ")
+	return
+}
+
 func Reader(r *http.Response) (dataBytes []byte, err error) {
 	switch r.StatusCode {
 	case http.StatusBadRequest:
@@ -48,63 +74,162 @@ func Reader(r *http.Response) (dataBytes []byte, err error) {
 		}
 		return
 	}
-	return dataBytes, nil
+	return nil, fmt.Errorf("%d unexpected response status", r.StatusCode)
 }
 
 // --------------------------------------------------------------------------------------------------- ProdMethod method to hhAPI query due
-func (hf HHfilterData) GetJobAnnounces() (hhResponseRest HHresponse, err error) {
-	if hf.VacancyName != "" {
-		uRqPreset := "https://api.hh.ru/vacancies?applicant_comments_order=creation_time_desc&per_page=100"
+// Полная выгрузка вакансий по шаблону.
+// Обходит все страницы выдачи. Если найдено больше, чем отдает ХэХ (hhDepthLimit),
+// запрос дробится по локациям (страна -> регион -> город), а для конечной локации - по интервалам дат публикации
+func (hf HHfilterData) GetJobAnnounces(ctx context.Context, areas bd.Countries) (hhResponseRest HHresponse, err error) {
+	if hf.VacancyName == "" {
+		return
+	}
+
+	hhResponseRest, err = hf.getJobAnnouncesPage(ctx, 0)
+	if err != nil {
+		return
+	}
+
+	if hhResponseRest.Found > hhDepthLimit {
+		return hf.splitJobAnnounces(ctx, areas)
+	}
 
-		uRqPreset += "&text=NAME%3A(" + hf.VacancyName + ")"
-		var hh htpcli.RequestDealer = &htpcli.HTTPclient{Socket: &http.Client{}}
-		getResp, err := hh.NewGet(uRqPreset, map[string]string{"User-Agent": "HH-User-Agent"}).Do()
+	for page := 1; page < hhResponseRest.Pages && (page+1)*hhPerPage <= hhDepthLimit; page++ {
+		rsp, err := hf.getJobAnnouncesPage(ctx, page)
 		if err != nil {
 			return hhResponseRest, err
 		}
+		hhResponseRest.Items = append(hhResponseRest.Items, rsp.Items...)
+	}
 
-		d, err := Reader(getResp)
-		json.Unmarshal(d, &hhResponseRest)
+	return hhResponseRest.uniqueItems(), nil
+}
 
-		return hhResponseRest, nil
+// Дробление запроса, упершегося в лимит глубины выдачи
+func (hf HHfilterData) splitJobAnnounces(ctx context.Context, areas bd.Countries) (hhResponseRest HHresponse, err error) {
+	var parts []HHfilterData
+
+	if subAreas := areas.FindChildLocationIDs(hf.Area); len(subAreas) != 0 {
+		for _, areaID := range subAreas {
+			part := hf
+			part.Area = areaID
+			parts = append(parts, part)
+		}
+	} else {
+		if hf.DateTo.IsZero() {
+			hf.DateTo = time.Now()
+		}
+		if hf.DateFrom.IsZero() {
+			hf.DateFrom = hf.DateTo.Add(-hhSearchPeriod)
+		}
+
+		if hf.DateTo.Sub(hf.DateFrom) < hhMinDateWindow {
+			logger.Error(fmt.Sprintf("hh harvest: pattern %q area %d window %s..%s exceeds depth limit, part of vacancies skipped", hf.VacancyName, hf.Area, hf.DateFrom.Format(hhDateLayout), hf.DateTo.Format(hhDateLayout)))
+			return hf.walkDepthLimit(ctx)
+		}
+
+		middle := hf.DateFrom.Add(hf.DateTo.Sub(hf.DateFrom) / 2)
+		older, newer := hf, hf
+		older.DateTo = middle
+		newer.DateFrom = middle
+		parts = append(parts, older, newer)
 	}
 
-	return
+	for _, part := range parts {
+		rsp, err := part.GetJobAnnounces(ctx, areas)
+		if err != nil {
+			return hhResponseRest, err
+		}
+		hhResponseRest.Found += rsp.Found
+		hhResponseRest.Items = append(hhResponseRest.Items, rsp.Items...)
+	}
+
+	hhResponseRest.PerPage = hhPerPage
+	return hhResponseRest.uniqueItems(), nil
 }
 
-// vacancy announce query to HHunter-API send
-func WorkerStart(pauseDuration int) {
-	time.Sleep(time.Duration(10) * time.Second)
+// Обход выдачи до лимита глубины, без дальнейшего дробления
+func (hf HHfilterData) walkDepthLimit(ctx context.Context) (hhResponseRest HHresponse, err error) {
+	for page := 0; page*hhPerPage < hhDepthLimit; page++ {
+		rsp, err := hf.getJobAnnouncesPage(ctx, page)
+		if err != nil {
+			return hhResponseRest, err
+		}
+		hhResponseRest.Found = rsp.Found
+		hhResponseRest.Pages = rsp.Pages
+		hhResponseRest.PerPage = rsp.PerPage
+		hhResponseRest.Items = append(hhResponseRest.Items, rsp.Items...)
+		if page+1 >= rsp.Pages {
+			break
+		}
+	}
+	return hhResponseRest.uniqueItems(), nil
+}
 
-	areas, err := bd.CountriesLis()
+// Одна страница выдачи ХэХа
+func (hf HHfilterData) getJobAnnouncesPage(ctx context.Context, page int) (hhResponseRest HHresponse, err error) {
+	uRqPreset := fmt.Sprintf("https://api.hh.ru/vacancies?applicant_comments_order=creation_time_desc&per_page=%d&page=%d", hhPerPage, page)
+
+	text, err := queryText(hf.VacancyName)
 	if err != nil {
-		logger.Error(err.Error())
-		panic(err)
+		return
 	}
+	uRqPreset += "&text=" + url.QueryEscape(text)
+	if hf.Area != 0 {
+		uRqPreset += "&area=" + strconv.Itoa(int(hf.Area))
+	}
+	if !hf.DateFrom.IsZero() {
+		uRqPreset += "&date_from=" + url.QueryEscape(hf.DateFrom.Format(hhDateLayout))
+	}
+	if !hf.DateTo.IsZero() {
+		uRqPreset += "&date_to=" + url.QueryEscape(hf.DateTo.Format(hhDateLayout))
+	}
+
+	var hh htpcli.RequestDealer = htpcli.New()
+	getResp, err := hh.NewGet(uRqPreset, map[string]string{"User-Agent": "HH-User-Agent"}).Do(ctx)
+	if err != nil {
+		return
+	}
+	defer getResp.Body.Close()
 
-	for {
-		keys, err := bd.GetVacancyPatterns()
+	d, err := Reader(getResp)
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(d, &hhResponseRest); err != nil {
+		err = fmt.Errorf("hh job announces page %d unmarshal error: %w", page, err)
+	}
+	return
+}
+
+// Отсев повторов: при дроблении запроса одна вакансия может попасть в несколько частей
+func (hh HHresponse) uniqueItems() HHresponse {
+	seen := make(map[string]bool, len(hh.Items))
+	items := make([]HHitem, 0, len(hh.Items))
+	for _, item := range hh.Items {
+		if seen[item.ID] {
+			continue
+		}
+		seen[item.ID] = true
+		items = append(items, item)
+	}
+	hh.Items = items
+	return hh
+}
+
+// currency rates refresher
+// Курсы в справочнике ХэХа обновляются раз в сутки, первая загрузка - в Init
+func CurrencyWorkerStart(ctx context.Context, pauseDuration int) {
+	for vacsource.Pause(ctx, time.Duration(pauseDuration)*time.Second) {
+		dict, err := GetSchedulesList(ctx)
 		if err != nil {
 			logger.Error(err.Error())
 			continue
 		}
-		for _, k := range ConvertSerchPatternModelDBtoHH(keys) {
-			resp, err := k.GetJobAnnounces()
-			if err != nil {
-				logger.Error(err.Error())
-				continue
-			}
-			if err = resp.ConvertItemsToDB(areas).SaveInDB(); err != nil {
-				logger.Error(err.Error())
-				continue
-			}
-			if len(keys) != 0 {
-				time.Sleep(time.Duration(pauseDuration/len(keys)) * time.Second)
-			}
-
+		if err = dict.CurrenciesModelConvert().SaveInDB(); err != nil {
+			logger.Error(err.Error())
 		}
-
-		time.Sleep(time.Duration(pauseDuration) * time.Second)
 	}
-
 }
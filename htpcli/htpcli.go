@@ -2,26 +2,73 @@ package htpcli
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type (
 	RequestDealer interface {
 		NewGet(url string, headers map[string]string) *HTTPclient
 		NewPost(url string, headers map[string]string, body []byte) *HTTPclient
-		Do() (resp *http.Response, err error)
+		Do(ctx context.Context) (resp *http.Response, err error)
 	}
 
 	HTTPclient struct {
 		Socket  *http.Client
+		Limiter *Limiter // nil - без ограничения частоты
+		Retry   RetryPolicy
 		Method  string
 		URL     string
 		Body    []byte
 		Headers map[string]string
 	}
+
+	// Повторы запроса: при сетевой ошибке, 429 и 5xx.
+	// Пауза растет экспоненциально от BaseDelay до MaxDelay со случайным разбросом,
+	// заголовок Retry-After ответа имеет приоритет
+	RetryPolicy struct {
+		MaxRetries int
+		BaseDelay  time.Duration
+		MaxDelay   time.Duration
+	}
+
+	Config struct {
+		Timeout    time.Duration
+		RPS        float64
+		Burst      int
+		MaxRetries int
+	}
+)
+
+var (
+	DefaultConfig = Config{Timeout: 30 * time.Second, RPS: 5, Burst: 5, MaxRetries: 4}
+
+	shared = newShared(DefaultConfig)
 )
 
+// Настройка общего для всех вызывающих клиента: таймаут, частота запросов, повторы
+func Init(conf Config) {
+	shared = newShared(conf)
+}
+
+func newShared(conf Config) *HTTPclient {
+	return &HTTPclient{
+		Socket:  &http.Client{Timeout: conf.Timeout},
+		Limiter: NewLimiter(conf.RPS, conf.Burst),
+		Retry:   RetryPolicy{MaxRetries: conf.MaxRetries, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second},
+	}
+}
+
+// Клиент на общем сокете и общем ограничителе частоты
+func New() *HTTPclient {
+	return &HTTPclient{Socket: shared.Socket, Limiter: shared.Limiter, Retry: shared.Retry}
+}
+
 // client types init
 func (cli *HTTPclient) NewGet(url string, headers map[string]string) *HTTPclient {
 	cli.Method = http.MethodGet
@@ -40,9 +87,63 @@ func (cli *HTTPclient) NewPost(url string, headers map[string]string, body []byt
 }
 
 // sent client request
-func (cli *HTTPclient) Do() (resp *http.Response, err error) {
-	reader := bytes.NewReader(cli.Body)
-	req, err := http.NewRequest(cli.Method, cli.URL, reader)
+// Последний ответ с ошибочным статусом возвращается как есть - статус разбирает вызывающий
+func (cli *HTTPclient) Do(ctx context.Context) (resp *http.Response, err error) {
+	socket := cli.Socket
+	if socket == nil {
+		socket = shared.Socket
+	}
+
+	for attempt := 0; ; attempt++ {
+		if cli.Limiter != nil {
+			if err = cli.Limiter.Wait(ctx); err != nil {
+				return nil, fmt.Errorf("request rate limiter waiting error: %w", err)
+			}
+		}
+
+		req, err := cli.buildRequest(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = socket.Do(req)
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, fmt.Errorf("request %s canceled: %w", cli.URL, ctx.Err())
+		}
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= cli.Retry.MaxRetries {
+			if err != nil {
+				err = fmt.Errorf("request %s failed after %d attempts: %w", cli.URL, attempt+1, err)
+			}
+			return resp, err
+		}
+
+		delay := cli.Retry.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request %s canceled: %w", cli.URL, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (cli *HTTPclient) buildRequest(ctx context.Context) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, cli.Method, cli.URL, bytes.NewReader(cli.Body))
 	if err != nil {
 		err = fmt.Errorf("request building error: %w", err)
 		return
@@ -54,7 +155,39 @@ func (cli *HTTPclient) Do() (resp *http.Response, err error) {
 			}
 		}
 	}
-
-	resp, err = cli.Socket.Do(req)
 	return
 }
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Экспоненциальная пауза со случайным разбросом в половину паузы
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	delay := rp.BaseDelay << attempt
+	if delay <= 0 || delay > rp.MaxDelay {
+		delay = rp.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Retry-After: секунды или HTTP-дата
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,86 @@
+package htpcli_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"vacancydealer/htpcli"
+)
+
+func TestDoRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	cli := &htpcli.HTTPclient{Socket: srv.Client(), Retry: htpcli.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}}
+	resp, err := cli.NewGet(srv.URL, nil).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("Result was incorrect, expected status %d after %d calls, got %d after %d", http.StatusOK, 3, resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	cli := &htpcli.HTTPclient{Socket: srv.Client(), Retry: htpcli.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}}
+	resp, err := cli.NewGet(srv.URL, nil).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 3 {
+		t.Errorf("Result was incorrect, expected status %d after %d calls, got %d after %d", http.StatusBadGateway, 3, resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	cli := &htpcli.HTTPclient{Socket: srv.Client(), Retry: htpcli.RetryPolicy{MaxRetries: 10, BaseDelay: time.Second, MaxDelay: time.Second}}
+	if _, err := cli.NewGet(srv.URL, nil).Do(ctx); err == nil {
+		t.Error("Expected error on canceled context")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := htpcli.NewLimiter(20, 1)
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("Limiter let 3 requests through in %s, expected at least %s", elapsed, 100*time.Millisecond)
+	}
+}
@@ -0,0 +1,61 @@
+package htpcli
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Ограничитель частоты запросов "ведро токенов": rate токенов в секунду, не больше burst про запас
+type Limiter struct {
+	mx     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// rate <= 0 - без ограничения
+func NewLimiter(rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Ожидание токена. Прерывается отменой контекста
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Забирает токен, если он есть, иначе возвращает время до появления токена
+func (l *Limiter) reserve() time.Duration {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"vacancydealer/bd"
 	"vacancydealer/confreader"
 	"vacancydealer/hh"
+	"vacancydealer/htpcli"
 	"vacancydealer/logger"
+	"vacancydealer/rssfeed"
+	"vacancydealer/superjob"
 	"vacancydealer/telebot"
+	"vacancydealer/vacsource"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	logger.InitInfoTextlog(os.Stdout)
 	logger.Info("logger status is Run...")
 
@@ -30,19 +43,84 @@ func main() {
 	if err = bd.Migrate(); err != nil {
 		logger.Error(err.Error())
 	}
-	go bd.StarWorker(bd.WorkDue)
+	var workers sync.WaitGroup
+	startWorker := func(worker func()) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker()
+		}()
+	}
+
+	startWorker(func() { bd.StarWorker(ctx, bd.WorkDue) })
 	logger.Info("database worker is Ready ...")
 
-	if err = hh.Init(); err != nil {
+	htpcli.Init(htpcli.Config{Timeout: conf.HTTP.Timeout, RPS: float64(conf.HTTP.RPS), Burst: conf.HTTP.Burst, MaxRetries: conf.HTTP.MaxRetries})
+
+	vacsource.Register(hh.Source{})
+	if conf.SJ.APIKey != "" {
+		vacsource.Register(superjob.New(conf.SJ.APIKey))
+	}
+	for _, src := range vacsource.List() {
+		if err = src.LoadDictionaries(ctx); err != nil {
+			logger.Error(err.Error())
+			shutdown(stop, &workers, conf.Shutdown)
+			os.Exit(1)
+		}
+	}
+	startWorker(func() { vacsource.WorkerStart(ctx, 3600, conf.HH.ResyncGap) })
+	startWorker(func() { vacsource.DetailsWorkerStart(ctx, time.Second) })
+	startWorker(func() { vacsource.SavedCheckerStart(ctx, 2*time.Second, 12*time.Hour) })
+	startWorker(func() { hh.CurrencyWorkerStart(ctx, 6*3600) })
+	logger.Info("vacancy sources worker is OK")
+
+	feeds := make(bd.JobFeeds, 0, len(conf.RSS.Feeds))
+	for _, f := range conf.RSS.Feeds {
+		feeds = append(feeds, bd.JobFeed{URL: f.URL, Company: f.Company, Enabled: true})
+	}
+	if err = feeds.SeedToDB(); err != nil {
 		logger.Error(err.Error())
-		return
 	}
-	go hh.WorkerStart(3600)
-	logger.Info("hh worker is OK")
+	startWorker(func() { rssfeed.WorkerStart(ctx, 1800) })
+	logger.Info("job feeds worker is OK")
 
 	logger.Info("telegram bot worker start")
-	if err := telebot.Run(conf.Tbot.API); err != nil {
+	startWorker(func() {
+		if err := telebot.Run(ctx, conf.Tbot.API); err != nil {
+			logger.Error(err.Error())
+			stop()
+		}
+	})
+
+	<-ctx.Done()
+	if !shutdown(stop, &workers, conf.Shutdown) {
+		os.Exit(1)
+	}
+}
+
+// Упорядоченное завершение: остановка воркеров, ожидание не дольше timeout и закрытие БД.
+// false - воркеры не успели остановиться или БД закрылась с ошибкой
+func shutdown(stop context.CancelFunc, workers *sync.WaitGroup, timeout time.Duration) bool {
+	stop() // повторный сигнал завершает процесс сразу
+	logger.Info("shutdown: waiting for workers")
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logger.Error(fmt.Sprintf("shutdown: workers did not stop within %s, forced termination", timeout))
+		return false
+	}
+
+	if err := bd.Close(); err != nil {
 		logger.Error(err.Error())
-		return
+		return false
 	}
+	logger.Info("shutdown complete")
+	return true
 }
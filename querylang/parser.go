@@ -0,0 +1,176 @@
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokPhrase
+	tokLParen
+	tokRParen
+	tokColon
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = map[string]tokenKind{"AND": tokAnd, "OR": tokOr, "NOT": tokNot}
+
+func lex(input string) (tokens []token, err error) {
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == ':':
+			tokens = append(tokens, token{kind: tokColon, text: ":", pos: i})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &SyntaxError{Pos: i, Msg: "не закрыта кавычка"}
+			}
+			phrase := strings.TrimSpace(string(runes[i+1 : end]))
+			if phrase == "" {
+				return nil, &SyntaxError{Pos: i, Msg: "пустая фраза в кавычках"}
+			}
+			tokens = append(tokens, token{kind: tokPhrase, text: phrase, pos: i})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`():"`, runes[end]) {
+				end++
+			}
+			word := string(runes[i:end])
+			kind := tokWord
+			if op, ok := operators[word]; ok {
+				kind = op
+			}
+			tokens = append(tokens, token{kind: kind, text: word, pos: i})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// Рекурсивный спуск: or -> and {OR and}; and -> unary {[AND] unary}; unary -> NOT unary | primary
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr(field Field) (n node, err error) {
+	if n, err = p.parseAnd(field); err != nil {
+		return
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd(field)
+		if err != nil {
+			return nil, err
+		}
+		n = or{Left: n, Right: right}
+	}
+	return
+}
+
+func (p *parser) parseAnd(field Field) (n node, err error) {
+	if n, err = p.parseUnary(field); err != nil {
+		return
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokWord, tokPhrase, tokLParen, tokNot:
+			// слова подряд - неявный AND
+		default:
+			return
+		}
+		right, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		n = and{Left: n, Right: right}
+	}
+}
+
+func (p *parser) parseUnary(field Field) (node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		x, err := p.parseUnary(field)
+		if err != nil {
+			return nil, err
+		}
+		return not{X: x}, nil
+	}
+	return p.parsePrimary(field)
+}
+
+func (p *parser) parsePrimary(field Field) (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		n, err := p.parseOr(field)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: "ожидается закрывающая скобка"}
+		}
+		return n, nil
+	case tokPhrase:
+		return term{Field: field, Text: t.text, Phrase: true}, nil
+	case tokWord:
+		if p.peek().kind != tokColon {
+			return term{Field: field, Text: t.text}, nil
+		}
+		prefixed, ok := fieldPrefixes[strings.ToLower(t.text)]
+		if !ok {
+			return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("неизвестное поле %q, доступны name, description, company", t.text)}
+		}
+		p.next()
+		if k := p.peek().kind; k != tokWord && k != tokPhrase && k != tokLParen {
+			return nil, &SyntaxError{Pos: p.peek().pos, Msg: "после поля ожидается слово, фраза или скобка"}
+		}
+		return p.parsePrimary(prefixed)
+	case tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "запрос оборвался, ожидается слово"}
+	default:
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("неожиданное %q", t.text)}
+	}
+}
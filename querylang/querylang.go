@@ -0,0 +1,237 @@
+// Язык поисковых запросов пользователя.
+//
+//	golang AND (backend OR "api developer") NOT 1С
+//	name:go company:"Рога и копыта" description:kafka
+//
+// Слова подряд объединяются через AND. Без префикса поле - name.
+// NOT допустим только в паре с положительным условием: этого требует язык запросов ХэХа
+package querylang
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Field string
+
+const (
+	FieldName        Field = "name"
+	FieldDescription Field = "description"
+	FieldCompany     Field = "company"
+)
+
+// префиксы полей в запросе
+var fieldPrefixes = map[string]Field{
+	"name":        FieldName,
+	"название":    FieldName,
+	"description": FieldDescription,
+	"описание":    FieldDescription,
+	"company":     FieldCompany,
+	"компания":    FieldCompany,
+}
+
+// поля в языке запросов ХэХа
+var hhFields = map[Field]string{
+	FieldName:        "NAME",
+	FieldDescription: "DESCRIPTION",
+	FieldCompany:     "COMPANY_NAME",
+}
+
+type (
+	node interface{}
+
+	term struct {
+		Field  Field
+		Text   string
+		Phrase bool
+	}
+	and struct{ Left, Right node }
+	or  struct{ Left, Right node }
+	not struct{ X node }
+
+	// Условие на слово или фразу запроса в поле field
+	Matcher func(field Field, text string) (predicate string, args []any)
+
+	// Разобранный запрос. Пустой запрос подходит под любую вакансию
+	Query struct {
+		root node
+	}
+
+	// Ошибка в запросе, текст для показа пользователю
+	SyntaxError struct {
+		Pos int // позиция в запросе, в символах. -1 - ошибка не в конкретном месте
+		Msg string
+	}
+)
+
+func (e *SyntaxError) Error() string {
+	if e.Pos < 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("позиция %d: %s", e.Pos+1, e.Msg)
+}
+
+func Parse(input string) (q Query, err error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return Query{}, nil
+	}
+
+	root, err := p.parseOr(FieldName)
+	if err != nil {
+		return
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return q, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("лишнее %q", t.text)}
+	}
+	if err = validate(root, false); err != nil {
+		return
+	}
+	return Query{root: root}, nil
+}
+
+func (q Query) Empty() bool {
+	return q.root == nil
+}
+
+// Запрос только из слов названия через AND, без OR, NOT и других полей.
+// Такой запрос сужается добавлением слов, и его шаблон можно заменить более коротким
+func (q Query) Conjunctive() bool {
+	var walk func(n node) bool
+	walk = func(n node) bool {
+		switch v := n.(type) {
+		case nil:
+			return true
+		case term:
+			return v.Field == FieldName
+		case and:
+			return walk(v.Left) && walk(v.Right)
+		}
+		return false
+	}
+	return walk(q.root)
+}
+
+const notPlacementMsg = "NOT можно использовать только вместе с условием, например: golang NOT 1С"
+
+// Проверка расстановки NOT: отрицание только вторым операндом AND рядом с положительным условием
+func validate(n node, allowNot bool) error {
+	switch v := n.(type) {
+	case not:
+		if !allowNot {
+			return &SyntaxError{Pos: -1, Msg: notPlacementMsg}
+		}
+		if _, ok := v.X.(not); ok {
+			return &SyntaxError{Pos: -1, Msg: "двойное NOT"}
+		}
+		return validate(v.X, false)
+	case and:
+		_, leftNot := v.Left.(not)
+		_, rightNot := v.Right.(not)
+		if leftNot && rightNot {
+			return &SyntaxError{Pos: -1, Msg: notPlacementMsg}
+		}
+		if err := validate(v.Left, true); err != nil {
+			return err
+		}
+		return validate(v.Right, true)
+	case or:
+		if err := validate(v.Left, false); err != nil {
+			return err
+		}
+		return validate(v.Right, false)
+	}
+	return nil
+}
+
+// Запрос на языке ХэХа для параметра text
+func (q Query) HHText() string {
+	if q.root == nil {
+		return ""
+	}
+	return hhText(q.root)
+}
+
+func hhText(n node) string {
+	switch v := n.(type) {
+	case term:
+		return hhFields[v.Field] + ":(" + v.quoted() + ")"
+	case and:
+		// у ХэХа исключение записывается как "a NOT b"
+		if x, ok := v.Left.(not); ok {
+			return "(" + hhText(v.Right) + " NOT " + hhText(x.X) + ")"
+		}
+		if x, ok := v.Right.(not); ok {
+			return "(" + hhText(v.Left) + " NOT " + hhText(x.X) + ")"
+		}
+		return "(" + hhText(v.Left) + " AND " + hhText(v.Right) + ")"
+	case or:
+		return "(" + hhText(v.Left) + " OR " + hhText(v.Right) + ")"
+	case not:
+		return "NOT " + hhText(v.X)
+	}
+	return ""
+}
+
+// SQL-условие запроса. match строит условие на одно слово или фразу
+func (q Query) SQL(match Matcher) (predicate string, args []any) {
+	if q.root == nil {
+		return "true", nil
+	}
+	return sqlPredicate(q.root, match, args)
+}
+
+func sqlPredicate(n node, match Matcher, args []any) (string, []any) {
+	switch v := n.(type) {
+	case term:
+		predicate, termArgs := match(v.Field, v.Text)
+		return predicate, append(args, termArgs...)
+	case and:
+		left, args := sqlPredicate(v.Left, match, args)
+		right, args := sqlPredicate(v.Right, match, args)
+		return "(" + left + " and " + right + ")", args
+	case or:
+		left, args := sqlPredicate(v.Left, match, args)
+		right, args := sqlPredicate(v.Right, match, args)
+		return "(" + left + " or " + right + ")", args
+	case not:
+		x, args := sqlPredicate(v.X, match, args)
+		return "not (" + x + ")", args
+	}
+	return "true", args
+}
+
+// Положительные слова и фразы запроса
+func (q Query) Terms() (terms []string) {
+	var walk func(n node)
+	walk = func(n node) {
+		switch v := n.(type) {
+		case term:
+			terms = append(terms, v.Text)
+		case and:
+			walk(v.Left)
+			walk(v.Right)
+		case or:
+			walk(v.Left)
+			walk(v.Right)
+		}
+	}
+	walk(q.root)
+	return
+}
+
+// Положительные слова запроса через пробел - для источников без языка запросов
+func (q Query) Keywords() string {
+	return strings.Join(q.Terms(), " ")
+}
+
+func (t term) quoted() string {
+	if t.Phrase || strings.ContainsAny(t.Text, " ") {
+		return `"` + t.Text + `"`
+	}
+	return t.Text
+}
@@ -0,0 +1,107 @@
+package querylang_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"vacancydealer/querylang"
+)
+
+var columns = map[querylang.Field]string{
+	querylang.FieldName:        "n",
+	querylang.FieldDescription: "d",
+	querylang.FieldCompany:     "c",
+}
+
+func match(field querylang.Field, text string) (string, []any) {
+	return columns[field] + " like ?", []any{"%" + strings.ToLower(text) + "%"}
+}
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		query    string
+		hh       string
+		sql      string
+		args     []any
+		keywords string
+	}{
+		{"golang", "NAME:(golang)", "n like ?", []any{"%golang%"}, "golang"},
+		{"golang developer", "(NAME:(golang) AND NAME:(developer))", "(n like ? and n like ?)", []any{"%golang%", "%developer%"}, "golang developer"},
+		{`go OR "Senior Lead"`, `(NAME:(go) OR NAME:("Senior Lead"))`, "(n like ? or n like ?)", []any{"%go%", "%senior lead%"}, "go Senior Lead"},
+		{"golang NOT 1С", "(NAME:(golang) NOT NAME:(1С))", "(n like ? and not (n like ?))", []any{"%golang%", "%1с%"}, "golang"},
+		{"NOT 1С golang", "(NAME:(golang) NOT NAME:(1С))", "(not (n like ?) and n like ?)", []any{"%1с%", "%golang%"}, "golang"},
+		{"company:Яндекс description:(kafka OR rabbitmq)", "(COMPANY_NAME:(Яндекс) AND (DESCRIPTION:(kafka) OR DESCRIPTION:(rabbitmq)))", "(c like ? and (d like ? or d like ?))", []any{"%яндекс%", "%kafka%", "%rabbitmq%"}, "Яндекс kafka rabbitmq"},
+		{"название:go AND (backend OR api)", "(NAME:(go) AND (NAME:(backend) OR NAME:(api)))", "(n like ? and (n like ? or n like ?))", []any{"%go%", "%backend%", "%api%"}, "go backend api"},
+	}
+
+	for _, c := range cases {
+		q, err := querylang.Parse(c.query)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", c.query, err)
+			continue
+		}
+		if got := q.HHText(); got != c.hh {
+			t.Errorf("Parse(%q).HHText() = %q, expected %q", c.query, got, c.hh)
+		}
+		sql, args := q.SQL(match)
+		if sql != c.sql || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("Parse(%q).SQL() = %q %v, expected %q %v", c.query, sql, args, c.sql, c.args)
+		}
+		if got := q.Keywords(); got != c.keywords {
+			t.Errorf("Parse(%q).Keywords() = %q, expected %q", c.query, got, c.keywords)
+		}
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	q, err := querylang.Parse("   ")
+	if err != nil || !q.Empty() {
+		t.Fatalf("empty query expected, got %v, %v", q, err)
+	}
+	if sql, args := q.SQL(match); sql != "true" || len(args) != 0 {
+		t.Errorf("empty query SQL = %q %v", sql, args)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, query := range []string{
+		`"golang`,
+		"(golang OR go",
+		"golang)",
+		"golang OR",
+		"salary:100",
+		"NOT 1С",
+		"golang OR NOT 1С",
+		"NOT NOT golang",
+		"name:",
+		`""`,
+	} {
+		_, err := querylang.Parse(query)
+		var syntaxErr *querylang.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) expected syntax error, got %v", query, err)
+		}
+	}
+}
+
+func TestConjunctive(t *testing.T) {
+	cases := map[string]bool{
+		"":                     true,
+		"golang developer":     true,
+		"golang AND backend":   true,
+		"golang OR rust":       false,
+		"golang NOT 1С":        false,
+		"company:Яндекс":       false,
+		"description:postgres": false,
+	}
+	for input, expected := range cases {
+		q, err := querylang.Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", input, err)
+		}
+		if q.Conjunctive() != expected {
+			t.Errorf("Conjunctive(%q) = %t, expected %t", input, !expected, expected)
+		}
+	}
+}
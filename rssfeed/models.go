@@ -0,0 +1,59 @@
+package rssfeed
+
+import "time"
+
+type (
+	// RSS 2.0
+	rssDocument struct {
+		Channel struct {
+			Title string    `xml:"title"`
+			Items []rssItem `xml:"item"`
+		} `xml:"channel"`
+	}
+	rssItem struct {
+		GUID        string `xml:"guid"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		PubDate     string `xml:"pubDate"`
+		Description string `xml:"description"`
+		Author      string `xml:"author"`
+		Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	}
+
+	// Atom
+	atomDocument struct {
+		Title   string      `xml:"title"`
+		Entries []atomEntry `xml:"entry"`
+	}
+	atomEntry struct {
+		ID        string     `xml:"id"`
+		Title     string     `xml:"title"`
+		Links     []atomLink `xml:"link"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+		Summary   string     `xml:"summary"`
+		Content   string     `xml:"content"`
+		Author    struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+	}
+	atomLink struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	}
+
+	// Запись ленты, общая для RSS и Atom
+	Entry struct {
+		GUID        string
+		Title       string
+		Link        string
+		Published   time.Time
+		Description string
+		Author      string
+	}
+
+	Feed struct {
+		Title   string
+		Entries []Entry
+	}
+)
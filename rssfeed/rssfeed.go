@@ -0,0 +1,210 @@
+package rssfeed
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/htpcli"
+	"vacancydealer/vacsource"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	SourceName = "rss"
+
+	snippetLength = 300 // длина фрагмента описания в рунах
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown feed format")
+
+	// "Вакансия at Компания", "Вакансия в Компания", "Вакансия — Компания", "Вакансия | Компания"
+	titleCompanySuffixRe = regexp.MustCompile(`^(.+?)\s+(?:at|@|в компанию|в компании|—|–|\|)\s+(.+)$`)
+	// "Компания: Вакансия"
+	titleCompanyPrefixRe = regexp.MustCompile(`^([^:]{2,60}):\s+(.+)$`)
+
+	dateLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC3339, time.RFC822Z, time.RFC822, "Mon, 2 Jan 2006 15:04:05 -0700", "2006-01-02T15:04:05Z07:00", "2006-01-02 15:04:05"}
+)
+
+// query to feed URL
+func GetFeed(ctx context.Context, url string) (feed Feed, err error) {
+	var cli htpcli.RequestDealer = htpcli.New()
+	r, err := cli.NewGet(url, map[string]string{"User-Agent": "vacancydealer-feed-reader"}).Do(ctx)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		err = fmt.Errorf("feed %s response status %d", url, r.StatusCode)
+		return
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if feed, err = ParseFeed(b); err != nil {
+		err = fmt.Errorf("feed %s parse error: %w", url, err)
+	}
+	return
+}
+
+// Разбор ленты RSS 2.0 или Atom
+func ParseFeed(b []byte) (feed Feed, err error) {
+	root, err := rootElement(b)
+	if err != nil {
+		return
+	}
+
+	switch root {
+	case "rss":
+		var doc rssDocument
+		if err = unmarshal(b, &doc); err != nil {
+			return
+		}
+		feed.Title = strings.TrimSpace(doc.Channel.Title)
+		for _, item := range doc.Channel.Items {
+			entry := Entry{GUID: strings.TrimSpace(item.GUID), Title: strings.TrimSpace(item.Title), Link: strings.TrimSpace(item.Link), Published: parseDate(item.PubDate), Description: item.Description, Author: strings.TrimSpace(item.Author)}
+			if entry.Author == "" {
+				entry.Author = strings.TrimSpace(item.Creator)
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+	case "feed":
+		var doc atomDocument
+		if err = unmarshal(b, &doc); err != nil {
+			return
+		}
+		feed.Title = strings.TrimSpace(doc.Title)
+		for _, item := range doc.Entries {
+			entry := Entry{GUID: strings.TrimSpace(item.ID), Title: strings.TrimSpace(item.Title), Published: parseDate(item.Published), Description: item.Summary, Author: strings.TrimSpace(item.Author.Name)}
+			if entry.Published.IsZero() {
+				entry.Published = parseDate(item.Updated)
+			}
+			if entry.Description == "" {
+				entry.Description = item.Content
+			}
+			for _, link := range item.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					entry.Link = strings.TrimSpace(link.Href)
+					break
+				}
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+	default:
+		err = fmt.Errorf("%w: root element <%s>", ErrUnknownFormat, root)
+	}
+	return
+}
+
+// Разбор XML в кодировке из его заголовка
+func unmarshal(b []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	decoder.CharsetReader = charsetReader
+	return decoder.Decode(v)
+}
+
+// Перекодировка в UTF-8: ленты бывают в windows-1251 и других кодировках
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, fmt.Errorf("feed charset %q error: %w", label, err)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// Имя корневого элемента документа
+func rootElement(b []byte) (name string, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	decoder.CharsetReader = charsetReader
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("feed root element reading error: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ------------------------------------->>>MODEL CONVERTERS-----------------------------------
+// Feed entries to models of package bd convert
+func (feed Feed) ConvertEntriesToDB(source bd.JobFeed) (to bd.JobAnnounces) {
+	for _, entry := range feed.Entries {
+		sourceID := entry.GUID
+		if sourceID == "" {
+			sourceID = entry.Link
+		}
+		if sourceID == "" || entry.Title == "" {
+			continue
+		}
+
+		name, company := splitTitle(entry.Title)
+		switch {
+		case source.Company != "":
+			company = source.Company
+		case company == "" && entry.Author != "":
+			company = entry.Author
+		case company == "":
+			company = feed.Title
+		}
+
+		// без даты - время первого появления проставит запись в БД
+		var published string
+		if !entry.Published.IsZero() {
+			published = entry.Published.Format(bd.PublishedAtLayout)
+		}
+
+		to = append(to, bd.JobAnnounce{
+			ItemId:      bd.ItemIDFor(SourceName, source.URL+" "+sourceID), // GUID уникален только в пределах ленты
+			Source:      SourceName,
+			SourceID:    sourceID,
+			Name:        name,
+			Company:     company,
+			PublishedAt: published,
+			Requirement: Snippet(entry.Description),
+			Link:        entry.Link,
+		})
+	}
+	return
+}
+
+// Эвристика: название вакансии и компания из заголовка записи
+func splitTitle(title string) (name, company string) {
+	if m := titleCompanySuffixRe.FindStringSubmatch(title); m != nil {
+		return strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+	}
+	if m := titleCompanyPrefixRe.FindStringSubmatch(title); m != nil {
+		return strings.TrimSpace(m[2]), strings.TrimSpace(m[1])
+	}
+	return title, ""
+}
+
+// Фрагмент описания: без разметки, в одну строку, не длиннее snippetLength рун
+func Snippet(description string) string {
+	return vacsource.Cut(strings.Join(strings.Fields(vacsource.PlainText(description)), " "), snippetLength)
+}
+
+// -------------------------------------<<<MODEL CONVERTERS-----------------------------------
@@ -0,0 +1,105 @@
+package rssfeed_test
+
+import (
+	"testing"
+	"vacancydealer/bd"
+	"vacancydealer/rssfeed"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const rssSample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Niche Go Jobs</title>
+	<item>
+		<guid>job-1</guid>
+		<title>Golang developer at Acme</title>
+		<link>https://jobs.example.com/1</link>
+		<pubDate>Thu, 17 Oct 2024 12:00:00 +0300</pubDate>
+		<description>&lt;p&gt;Go, &lt;b&gt;PostgreSQL&lt;/b&gt;&lt;/p&gt;</description>
+	</item>
+	<item>
+		<title>Ромашка: Backend-разработчик</title>
+		<link>https://jobs.example.com/2</link>
+		<dc:creator>hr@romashka</dc:creator>
+	</item>
+</channel>
+</rss>`
+
+const atomSample = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Company careers</title>
+	<entry>
+		<id>urn:job:42</id>
+		<title>SRE</title>
+		<link rel="alternate" href="https://careers.example.com/42"/>
+		<updated>2024-10-17T09:00:00Z</updated>
+		<summary>On-call rotation</summary>
+		<author><name>Example Corp</name></author>
+	</entry>
+</feed>`
+
+func TestParseRSS(t *testing.T) {
+	feed, err := rssfeed.ParseFeed([]byte(rssSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := feed.ConvertEntriesToDB(bd.JobFeed{})
+	if len(items) != 2 {
+		t.Fatalf("Result was incorrect, expected %d items, got %d", 2, len(items))
+	}
+	if items[0].Name != "Golang developer" || items[0].Company != "Acme" || items[0].Requirement != "Go, PostgreSQL" || items[0].PublishedAt != "2024-10-17T12:00:00+0300" {
+		t.Errorf("First entry was incorrect: %+v", items[0])
+	}
+	if items[1].Name != "Backend-разработчик" || items[1].Company != "Ромашка" || items[1].SourceID != "https://jobs.example.com/2" {
+		t.Errorf("Second entry was incorrect: %+v", items[1])
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	feed, err := rssfeed.ParseFeed([]byte(atomSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := feed.ConvertEntriesToDB(bd.JobFeed{})
+	if len(items) != 1 || items[0].Company != "Example Corp" || items[0].Link != "https://careers.example.com/42" || items[0].SourceID != "urn:job:42" {
+		t.Errorf("Result was incorrect: %+v", items)
+	}
+
+	// одинаковый GUID в разных лентах - разные вакансии
+	first, second := feed.ConvertEntriesToDB(bd.JobFeed{URL: "https://a.example.com/feed"}), feed.ConvertEntriesToDB(bd.JobFeed{URL: "https://b.example.com/feed"})
+	if first[0].ItemId == second[0].ItemId {
+		t.Errorf("Entries of different feeds share item id %d", first[0].ItemId)
+	}
+
+	if items = feed.ConvertEntriesToDB(bd.JobFeed{Company: "Configured"}); items[0].Company != "Configured" {
+		t.Errorf("Feed company was not applied: %s", items[0].Company)
+	}
+}
+
+func TestParseUnknown(t *testing.T) {
+	if _, err := rssfeed.ParseFeed([]byte(`<html><body/></html>`)); err == nil {
+		t.Error("Expected error on unknown feed format")
+	}
+}
+
+func TestParseWindows1251(t *testing.T) {
+	sample, err := charmap.Windows1251.NewEncoder().String(`<?xml version="1.0" encoding="windows-1251"?>
+<rss version="2.0"><channel><title>Вакансии</title>
+	<item><guid>job-1</guid><title>Разработчик Go - Ромашка</title><link>https://jobs.example.com/1</link></item>
+</channel></rss>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := rssfeed.ParseFeed([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Title != "Вакансии" || len(feed.Entries) != 1 || feed.Entries[0].Title != "Разработчик Go - Ромашка" {
+		t.Errorf("Result was incorrect: %+v", feed)
+	}
+}
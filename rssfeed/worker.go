@@ -0,0 +1,48 @@
+package rssfeed
+
+import (
+	"context"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+	"vacancydealer/vacsource"
+)
+
+// feeds poller
+// Обходит включенные ленты из bd.JobFeed и пишет записи в job_announces
+func WorkerStart(ctx context.Context, pauseDuration int) {
+	for {
+		feeds, err := bd.GetEnabledFeeds(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+
+		for _, f := range feeds {
+			if ctx.Err() != nil {
+				return
+			}
+
+			feed, err := GetFeed(ctx, f.URL)
+			if err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			items, err := feed.ConvertEntriesToDB(f).Deduplicate(ctx)
+			if err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			if err = items.SaveInDB(ctx); err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			if err = f.UpdatePolled(time.Now()); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+
+		if !vacsource.Pause(ctx, time.Duration(pauseDuration)*time.Second) {
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Оценка вакансии для пользователя: насколько название совпадает с запросом, зарплата с ожиданием,
+// насколько вакансия свежая, близко ли она и проверен ли работодатель.
+// Оценка 0..100 и причины высокой оценки для карточки
+package scoring
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	weightTitle     = 0.30
+	weightSalary    = 0.20
+	weightFreshness = 0.20
+	weightLocation  = 0.15
+	weightTrusted   = 0.15
+
+	freshnessHalfLife = 72 * time.Hour // за это время свежесть падает вдвое
+	salaryHeadroom    = 1.2            // зарплата выше ожидания на 20% - максимальная оценка
+	reasonThreshold   = 0.6            // фактор с меньшим значением не попадает в причины
+	maxReasons        = 2
+)
+
+// Удаленность вакансии от локации пользователя
+type Location int
+
+const (
+	LocationUnknown     Location = iota // у пользователя или вакансии локация не указана
+	LocationExact                       // внутри выбранной пользователем локации
+	LocationSameRegion                  // в том же регионе
+	LocationSameCountry                 // в той же стране
+	LocationFar
+)
+
+type (
+	Vacancy struct {
+		NameNorm    string  // название в нормальной форме textnorm.Normalize
+		Salary      float64 // верхняя граница вилки в валюте пользователя, 0 - не указана
+		PublishedAt time.Time
+		Location    Location
+		Trusted     bool
+	}
+
+	Expectation struct {
+		Terms     []string // слова запроса в нормальной форме textnorm.Normalize
+		SalaryMin float64  // 0 - не важна
+	}
+
+	Result struct {
+		Score   int // 0..100
+		Reasons []string
+	}
+
+	factor struct {
+		weight float64
+		value  float64 // 0..1
+		reason string
+	}
+)
+
+func Score(v Vacancy, e Expectation, now time.Time) Result {
+	factors := []factor{
+		titleFactor(v, e),
+		salaryFactor(v, e),
+		freshnessFactor(v, now),
+		locationFactor(v),
+		trustedFactor(v),
+	}
+
+	var score float64
+	for _, f := range factors {
+		score += f.weight * f.value
+	}
+
+	// причины - самые весомые из сильных факторов
+	sort.SliceStable(factors, func(i, j int) bool {
+		return factors[i].weight*factors[i].value > factors[j].weight*factors[j].value
+	})
+	var reasons []string
+	for _, f := range factors {
+		if f.value >= reasonThreshold && f.reason != "" && len(reasons) < maxReasons {
+			reasons = append(reasons, f.reason)
+		}
+	}
+
+	return Result{Score: int(math.Round(score * 100)), Reasons: reasons}
+}
+
+// Доля слов запроса, найденных в названии
+func titleFactor(v Vacancy, e Expectation) factor {
+	f := factor{weight: weightTitle, value: 0.5}
+	if len(e.Terms) == 0 {
+		return f
+	}
+
+	var found int
+	for _, t := range e.Terms {
+		if t != "" && strings.Contains(v.NameNorm, t) {
+			found++
+		}
+	}
+	f.value = float64(found) / float64(len(e.Terms))
+	switch {
+	case f.value == 1:
+		f.reason = "все слова запроса в названии"
+	case f.value >= reasonThreshold:
+		f.reason = "большинство слов запроса в названии"
+	}
+	return f
+}
+
+func salaryFactor(v Vacancy, e Expectation) factor {
+	f := factor{weight: weightSalary}
+	switch {
+	case e.SalaryMin <= 0 && v.Salary > 0:
+		f.value, f.reason = 0.7, "зарплата указана"
+	case e.SalaryMin <= 0 || v.Salary <= 0:
+		f.value = 0.3
+	default:
+		ratio := v.Salary / e.SalaryMin
+		f.value = math.Min(ratio, salaryHeadroom) / salaryHeadroom
+		switch {
+		case ratio >= salaryHeadroom:
+			f.reason = "зарплата выше ожиданий"
+		case ratio >= 1:
+			f.reason = "зарплата на уровне ожиданий"
+		}
+	}
+	return f
+}
+
+func freshnessFactor(v Vacancy, now time.Time) factor {
+	f := factor{weight: weightFreshness}
+	if v.PublishedAt.IsZero() {
+		return f
+	}
+
+	age := now.Sub(v.PublishedAt)
+	if age < 0 {
+		age = 0
+	}
+	f.value = math.Pow(0.5, float64(age)/float64(freshnessHalfLife))
+	switch {
+	case age < 24*time.Hour:
+		f.reason = "опубликована менее суток назад"
+	case age < freshnessHalfLife:
+		f.reason = "свежая вакансия"
+	}
+	return f
+}
+
+func locationFactor(v Vacancy) factor {
+	f := factor{weight: weightLocation}
+	switch v.Location {
+	case LocationExact:
+		f.value, f.reason = 1, "в выбранной локации"
+	case LocationSameRegion:
+		f.value, f.reason = 0.6, "в том же регионе"
+	case LocationSameCountry:
+		f.value = 0.3
+	case LocationUnknown:
+		f.value = 0.5
+	}
+	return f
+}
+
+func trustedFactor(v Vacancy) factor {
+	f := factor{weight: weightTrusted}
+	if v.Trusted {
+		f.value, f.reason = 1, "проверенный работодатель"
+	}
+	return f
+}
@@ -0,0 +1,49 @@
+package scoring_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"vacancydealer/scoring"
+)
+
+var now = time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func TestScoreOrder(t *testing.T) {
+	e := scoring.Expectation{Terms: []string{" golang ", " разработчик "}, SalaryMin: 200000}
+
+	best := scoring.Vacancy{NameNorm: " golang разработчик ", Salary: 300000, PublishedAt: now.Add(-2 * time.Hour), Location: scoring.LocationExact, Trusted: true}
+	middle := scoring.Vacancy{NameNorm: " golang developer ", Salary: 200000, PublishedAt: now.Add(-72 * time.Hour), Location: scoring.LocationSameRegion}
+	worst := scoring.Vacancy{NameNorm: " менеджер ", PublishedAt: now.Add(-30 * 24 * time.Hour), Location: scoring.LocationFar}
+
+	b, m, w := scoring.Score(best, e, now), scoring.Score(middle, e, now), scoring.Score(worst, e, now)
+	if !(b.Score > m.Score && m.Score > w.Score) {
+		t.Errorf("scores order broken: best %d, middle %d, worst %d", b.Score, m.Score, w.Score)
+	}
+	if b.Score < 95 || b.Score > 100 {
+		t.Errorf("best score = %d, expected 95..100", b.Score)
+	}
+	if len(w.Reasons) != 0 {
+		t.Errorf("worst reasons = %q, expected none", w.Reasons)
+	}
+}
+
+func TestScoreReasons(t *testing.T) {
+	e := scoring.Expectation{Terms: []string{" golang "}, SalaryMin: 100000}
+	v := scoring.Vacancy{NameNorm: " golang разработчик ", Salary: 150000, Trusted: true}
+
+	expected := []string{"все слова запроса в названии", "зарплата выше ожиданий"}
+	if got := scoring.Score(v, e, now).Reasons; !reflect.DeepEqual(got, expected) {
+		t.Errorf("reasons = %q, expected %q", got, expected)
+	}
+}
+
+func TestScoreFreshness(t *testing.T) {
+	var e scoring.Expectation
+	fresh := scoring.Score(scoring.Vacancy{PublishedAt: now.Add(-time.Hour)}, e, now)
+	old := scoring.Score(scoring.Vacancy{PublishedAt: now.Add(-10 * 24 * time.Hour)}, e, now)
+	unknown := scoring.Score(scoring.Vacancy{}, e, now)
+	if !(fresh.Score > old.Score && old.Score >= unknown.Score) {
+		t.Errorf("freshness order broken: fresh %d, old %d, unknown %d", fresh.Score, old.Score, unknown.Score)
+	}
+}
@@ -0,0 +1,76 @@
+package superjob
+
+type (
+	// for superjob vacancy search query
+	SJresponse struct {
+		Objects []SJvacancy `json:"objects"`
+		Total   int         `json:"total"`
+		More    bool        `json:"more"`
+	}
+	SJvacancy struct {
+		ID            int          `json:"id"`
+		Profession    string       `json:"profession"`
+		FirmName      string       `json:"firm_name"`
+		Town          TownEntity   `json:"town"`
+		PaymentFrom   float64      `json:"payment_from"`
+		PaymentTo     float64      `json:"payment_to"`
+		Currency      string       `json:"currency"`
+		DatePublished int64        `json:"date_published"`
+		Link          string       `json:"link"`
+		Candidat      string       `json:"candidat"`
+		Work          string       `json:"work"`
+		TypeOfWork    DictEntity   `json:"type_of_work"`
+		PlaceOfWork   DictEntity   `json:"place_of_work"`
+		Experience    DictEntity   `json:"experience"`
+		Client        ClientEntity `json:"client"`
+
+		// полная карточка
+		VacancyRichText string        `json:"vacancyRichText"`
+		Address         string        `json:"address"`
+		Contact         string        `json:"contact"`
+		Phones          []PhoneEntity `json:"phones"`
+		Catalogues      []SJcatalogue `json:"catalogues"`
+	}
+	PhoneEntity struct {
+		Number string `json:"number"`
+	}
+	SJcatalogue struct {
+		ID        int          `json:"id"`
+		Title     string       `json:"title"`
+		Positions []DictEntity `json:"positions"`
+	}
+	TownEntity struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	DictEntity struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	ClientEntity struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+
+	// for superjob towns index query
+	TownsResponse struct {
+		Objects []Town `json:"objects"`
+		Total   int    `json:"total"`
+		More    bool   `json:"more"`
+	}
+	Town struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+
+	// for superjob catalogues index query
+	Catalogue struct {
+		Key       int        `json:"key"`
+		Title     string     `json:"title_rus"`
+		Positions []Position `json:"positions"`
+	}
+	Position struct {
+		Key   int    `json:"key"`
+		Title string `json:"title_rus"`
+	}
+)
@@ -0,0 +1,417 @@
+package superjob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/htpcli"
+	"vacancydealer/logger"
+	"vacancydealer/querylang"
+	"vacancydealer/vacsource"
+)
+
+const (
+	SourceName     = "superjob"
+	DefaultBaseURL = "https://api.superjob.ru/2.0"
+
+	sjPerPage       = 100 // максимум элементов на странице выдачи
+	sjDepthLimit    = 500 // SuperJob не отдает больше 500 элементов на один запрос
+	sjSearchPeriod  = 30 * 24 * time.Hour
+	sjMinDateWindow = time.Hour // минимальный интервал дат, до которого дробится запрос
+	sjMaxTownsParam = 100
+)
+
+var (
+	// type_of_work SuperJob -> график работы ХэХа (справочник bd.Schedule)
+	typeOfWorkSchedules = map[int]string{6: "fullDay", 12: "shift", 10: "flexible", 13: "flexible", 7: "flexible", 9: "flyInFlyOut"}
+	// опыт работы SuperJob -> опыт работы ХэХа
+	experienceIDs = map[int]string{1: "noExperience", 2: "between1And3", 3: "between3And6", 4: "moreThan6"}
+	// валюта SuperJob -> код валюты ХэХа
+	currencyCodes = map[string]string{"rub": "RUR", "uah": "UAH", "uzs": "UZS"}
+)
+
+// place_of_work "на дому"
+const placeOfWorkRemote = 2
+
+// SuperJob как источник вакансий
+type Source struct {
+	APIKey  string
+	BaseURL string
+	// town_id SuperJob -> ИД локации в дереве bd.CountrySQL/Region/City
+	Towns map[int]uint
+	// дерево локаций, для поиска по региону или стране
+	Areas bd.Countries
+}
+
+var _ vacsource.VacancySource = (*Source)(nil)
+
+func New(apiKey string) *Source {
+	return &Source{APIKey: apiKey, BaseURL: DefaultBaseURL, Towns: map[int]uint{}}
+}
+
+func (s *Source) Name() string {
+	return SourceName
+}
+
+// Справочники SuperJob: города сопоставляются с деревом локаций,
+// графики работ дописываются в справочник графиков, каталог профессий пишется как есть
+func (s *Source) LoadDictionaries(ctx context.Context) (err error) {
+	areas, err := bd.CountriesLis()
+	if err != nil {
+		return
+	}
+
+	towns, err := s.GetTowns(ctx)
+	if err != nil {
+		return
+	}
+	s.Towns = MapTowns(towns, areas)
+	s.Areas = areas
+
+	sourceAreas := make(bd.SourceAreas, 0, len(s.Towns))
+	for townID, areaID := range s.Towns {
+		sourceAreas = append(sourceAreas, bd.SourceArea{Source: SourceName, SourceID: strconv.Itoa(townID), AreaID: areaID})
+	}
+	if err = sourceAreas.SaveInDB(); err != nil {
+		return
+	}
+
+	if err = schedulesDictionary().CreateMissingToDB(); err != nil {
+		return
+	}
+
+	catalogues, err := s.GetCatalogues(ctx)
+	if err != nil {
+		return
+	}
+	return ConvertCatalogues(catalogues).SaveInDB()
+}
+
+func (s *Source) Search(ctx context.Context, filter vacsource.Filter, perPage, page int) (result vacsource.Page, err error) {
+	params := url.Values{}
+	params.Set("keyword", keywords(filter.VacancyName))
+	params.Set("count", strconv.Itoa(perPage))
+	params.Set("page", strconv.Itoa(page))
+	for id, exp := range experienceIDs {
+		if exp == bd.ExperienceCode(filter.ExperienceYear) {
+			params.Set("experience", strconv.Itoa(id))
+		}
+	}
+	if filter.Schedule == "remote" {
+		params.Set("place_of_work", strconv.Itoa(placeOfWorkRemote))
+	} else {
+		for id, sched := range typeOfWorkSchedules {
+			if sched == filter.Schedule {
+				params.Add("type_of_work[]", strconv.Itoa(id))
+			}
+		}
+	}
+	if filter.SalaryMin > 0 && (filter.SalaryCurrency == "" || filter.SalaryCurrency == "RUR") { // payment_from - только в рублях
+		params.Set("payment_from", strconv.Itoa(filter.SalaryMin))
+	}
+	if filter.OnlyWithSalary {
+		params.Set("no_agreement", "1")
+	}
+	// без town[] выдача идет по всем городам - тогда вакансии вне локации отсеиваются по выдаче
+	var locations map[uint]bool
+	if filter.Location != 0 {
+		locations = map[uint]bool{filter.Location: true}
+		for _, id := range s.Areas.FindContainLocationIDsList(filter.Location) {
+			locations[id] = true
+		}
+		var towns []string
+		for townID, areaID := range s.Towns {
+			if locations[areaID] {
+				towns = append(towns, strconv.Itoa(townID))
+			}
+		}
+		// для страны целиком городов слишком много для строки запроса
+		if len(towns) != 0 && len(towns) <= sjMaxTownsParam {
+			params["town[]"] = towns
+			locations = nil
+		}
+	}
+
+	rsp, err := s.getVacancies(ctx, params)
+	if err != nil {
+		err = fmt.Errorf("superjob search error: %w", err)
+		return
+	}
+
+	items := s.ConvertVacancies(rsp.Objects)
+	if locations != nil {
+		items = inLocations(items, locations)
+	}
+	result = vacsource.Page{Found: rsp.Total, Items: filter.Exclude(items)}
+	if perPage != 0 {
+		result.Pages = (rsp.Total + perPage - 1) / perPage
+	}
+	return
+}
+
+// Вакансии из городов локации, вакансии неизвестных городов тоже отсеиваются
+func inLocations(items bd.JobAnnounces, locations map[uint]bool) (in bd.JobAnnounces) {
+	in = make(bd.JobAnnounces, 0, len(items))
+	for _, a := range items {
+		if locations[uint(a.Area)] {
+			in = append(in, a)
+		}
+	}
+	return
+}
+
+// Выгрузка по шаблону. Если найдено больше лимита глубины - запрос дробится по интервалам дат публикации
+func (s *Source) Harvest(ctx context.Context, pattern string, dateFrom time.Time, areas bd.Countries) (items bd.JobAnnounces, err error) {
+	dateTo := time.Now()
+	if dateFrom.IsZero() {
+		dateFrom = dateTo.Add(-sjSearchPeriod)
+	}
+
+	vacancies, err := s.harvestWindow(ctx, pattern, dateFrom, dateTo)
+	if err != nil {
+		err = fmt.Errorf("superjob harvest of pattern %q error: %w", pattern, err)
+		return
+	}
+	return s.ConvertVacancies(vacancies), nil
+}
+
+// У SuperJob нет языка запросов - в поиск уходят только положительные слова запроса
+func keywords(vacancyName string) string {
+	q, err := querylang.Parse(vacancyName)
+	if err != nil {
+		return vacancyName
+	}
+	return q.Keywords()
+}
+
+func (s *Source) harvestWindow(ctx context.Context, pattern string, dateFrom, dateTo time.Time) (vacancies []SJvacancy, err error) {
+	params := url.Values{}
+	params.Set("keyword", keywords(pattern))
+	params.Set("count", strconv.Itoa(sjPerPage))
+	params.Set("date_published_from", strconv.FormatInt(dateFrom.Unix(), 10))
+	params.Set("date_published_to", strconv.FormatInt(dateTo.Unix(), 10))
+
+	for page := 0; page*sjPerPage < sjDepthLimit; page++ {
+		params.Set("page", strconv.Itoa(page))
+		rsp, err := s.getVacancies(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if page == 0 && rsp.Total > sjDepthLimit {
+			if dateTo.Sub(dateFrom) >= sjMinDateWindow {
+				middle := dateFrom.Add(dateTo.Sub(dateFrom) / 2)
+				older, err := s.harvestWindow(ctx, pattern, dateFrom, middle)
+				if err != nil {
+					return nil, err
+				}
+				newer, err := s.harvestWindow(ctx, pattern, middle, dateTo)
+				if err != nil {
+					return nil, err
+				}
+				return append(older, newer...), nil
+			}
+			logger.Error(fmt.Sprintf("superjob harvest: pattern %q window %s..%s exceeds depth limit, part of vacancies skipped", pattern, dateFrom.Format(bd.PublishedAtLayout), dateTo.Format(bd.PublishedAtLayout)))
+		}
+
+		vacancies = append(vacancies, rsp.Objects...)
+		if !rsp.More {
+			break
+		}
+	}
+	return
+}
+
+func (s *Source) VacancyDetails(ctx context.Context, sourceID string) (announce bd.JobAnnounce, err error) {
+	var vac SJvacancy
+	if err = s.get(ctx, "/vacancies/"+url.PathEscape(sourceID)+"/", nil, &vac); err != nil {
+		err = fmt.Errorf("superjob vacancy %s details error: %w", sourceID, err)
+		return
+	}
+	items := s.ConvertVacancies([]SJvacancy{vac})
+
+	announce = items[0]
+	announce.VacancyDetails = vac.convertDetails()
+	return announce, nil
+}
+
+// query to SuperJob API
+func (s *Source) getVacancies(ctx context.Context, params url.Values) (rsp SJresponse, err error) {
+	err = s.get(ctx, "/vacancies/", params, &rsp)
+	return
+}
+
+// query to SuperJob API
+// Все города
+func (s *Source) GetTowns(ctx context.Context) (towns []Town, err error) {
+	var rsp TownsResponse
+	if err = s.get(ctx, "/towns/", url.Values{"all": {"1"}}, &rsp); err != nil {
+		err = fmt.Errorf("superjob towns getting error: %w", err)
+		return
+	}
+	return rsp.Objects, nil
+}
+
+// query to SuperJob API
+// Каталог отраслей и профессий
+func (s *Source) GetCatalogues(ctx context.Context) (catalogues []Catalogue, err error) {
+	if err = s.get(ctx, "/catalogues/", nil, &catalogues); err != nil {
+		err = fmt.Errorf("superjob catalogues getting error: %w", err)
+	}
+	return
+}
+
+func (s *Source) get(ctx context.Context, path string, params url.Values, rsp any) (err error) {
+	urq := strings.TrimRight(s.BaseURL, "/") + path
+	if len(params) != 0 {
+		urq += "?" + params.Encode()
+	}
+
+	var sj htpcli.RequestDealer = htpcli.New()
+	r, err := sj.NewGet(urq, map[string]string{"X-Api-App-Id": s.APIKey}).Do(ctx)
+	if err != nil {
+		return
+	}
+	defer r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return fmt.Errorf("superjob %s: %w", path, vacsource.ErrVacancyNotFound)
+	default:
+		return fmt.Errorf("superjob %s response status %d", path, r.StatusCode)
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, rsp); err != nil {
+		err = fmt.Errorf("superjob %s unmarshal error: %w", path, err)
+	}
+	return
+}
+
+// ------------------------------------->>>MODEL CONVERTERS-----------------------------------
+// SuperJob vacancies to models of package bd convert
+func (s *Source) ConvertVacancies(from []SJvacancy) (to bd.JobAnnounces) {
+	for _, vac := range from {
+		sourceID := strconv.Itoa(vac.ID)
+
+		schedule := typeOfWorkSchedules[vac.TypeOfWork.ID]
+		if vac.PlaceOfWork.ID == placeOfWorkRemote {
+			schedule = "remote"
+		}
+
+		currency, ok := currencyCodes[vac.Currency]
+		if !ok {
+			currency = strings.ToUpper(vac.Currency)
+		}
+
+		company := vac.FirmName
+		if company == "" {
+			company = vac.Client.Title
+		}
+
+		to = append(to, bd.JobAnnounce{
+			ItemId:         bd.ItemIDFor(SourceName, sourceID),
+			Source:         SourceName,
+			SourceID:       sourceID,
+			Name:           vac.Profession,
+			Company:        company,
+			Area:           int(s.Towns[vac.Town.ID]),
+			Expierence:     experienceIDs[vac.Experience.ID],
+			SalaryFrom:     vac.PaymentFrom,
+			SalaryTo:       vac.PaymentTo,
+			SalaryCurrency: currency,
+			PublishedAt:    time.Unix(vac.DatePublished, 0).Format(bd.PublishedAtLayout),
+			Schedule:       schedule,
+			Requirement:    vac.Candidat,
+			Responsebility: vac.Work,
+			Link:           vac.Link,
+		})
+	}
+	return
+}
+
+// Подробности вакансии to model of package bd convert
+func (vac SJvacancy) convertDetails() (details bd.VacancyDetails) {
+	details = bd.VacancyDetails{Description: vacsource.PlainText(vac.VacancyRichText), Employment: vac.TypeOfWork.Title, Address: vac.Address, HasContacts: vac.Contact != "" || len(vac.Phones) != 0}
+	if details.Description == "" {
+		details.Description = strings.TrimSpace(vac.Candidat + "\n" + vac.Work)
+	}
+
+	var roles []string
+	for _, c := range vac.Catalogues {
+		for _, p := range c.Positions {
+			roles = append(roles, p.Title)
+		}
+	}
+	details.ProfessionalRoles = strings.Join(roles, ", ")
+	return
+}
+
+// Сопоставление городов SuperJob с деревом локаций по названию.
+// Сначала ищется город, затем регион: Москва и Санкт-Петербург в дереве ХэХа - регионы без городов
+func MapTowns(towns []Town, areas bd.Countries) (mapping map[int]uint) {
+	cities := make(map[string]uint)
+	regions := make(map[string]uint)
+	for _, country := range areas {
+		for _, region := range country.Regions {
+			if _, ok := regions[normalizeName(region.Region.Name)]; !ok {
+				regions[normalizeName(region.Region.Name)] = region.Region.ID
+			}
+			for _, city := range region.Cities {
+				if _, ok := cities[normalizeName(city.Name)]; !ok {
+					cities[normalizeName(city.Name)] = city.ID
+				}
+			}
+		}
+	}
+
+	mapping = make(map[int]uint, len(towns))
+	for _, town := range towns {
+		name := normalizeName(town.Title)
+		if id, ok := cities[name]; ok {
+			mapping[town.ID] = id
+		} else if id, ok := regions[name]; ok {
+			mapping[town.ID] = id
+		}
+	}
+	return
+}
+
+// Каталог SuperJob to models of package bd convert
+func ConvertCatalogues(from []Catalogue) (to bd.Catalogues) {
+	for _, c := range from {
+		to = append(to, bd.Catalogue{Source: SourceName, SourceID: strconv.Itoa(c.Key), Title: c.Title})
+		for _, p := range c.Positions {
+			to = append(to, bd.Catalogue{Source: SourceName, SourceID: strconv.Itoa(p.Key), Title: p.Title, ParentID: strconv.Itoa(c.Key)})
+		}
+	}
+	return
+}
+
+// Графики SuperJob, приведенные к справочнику графиков ХэХа
+func schedulesDictionary() bd.Schedules {
+	return bd.Schedules{
+		{HhID: "fullDay", Name: "Полный день"},
+		{HhID: "shift", Name: "Сменный график"},
+		{HhID: "flexible", Name: "Гибкий график"},
+		{HhID: "remote", Name: "Удаленная работа"},
+		{HhID: "flyInFlyOut", Name: "Вахтовый метод"},
+	}
+}
+
+func normalizeName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "ё", "е")
+}
+
+// -------------------------------------<<<MODEL CONVERTERS-----------------------------------
@@ -0,0 +1,134 @@
+package superjob_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"vacancydealer/bd"
+	"vacancydealer/superjob"
+	"vacancydealer/vacsource"
+)
+
+// Локальный сервер, отдающий записанные ответы SuperJob
+func fixtureServer(t *testing.T) *httptest.Server {
+	fixtures := map[string]string{
+		"/vacancies/":  "testdata/vacancies.json",
+		"/towns/":      "testdata/towns.json",
+		"/catalogues/": "testdata/catalogues.json",
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-App-Id") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		file, ok := fixtures[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testAreas() bd.Countries {
+	return bd.Countries{
+		{Count: bd.AreaEntity{ID: 113, Name: "Россия"}, Regions: bd.Regions{
+			{Region: bd.AreaEntity{ID: 1, Name: "Москва", Owner: 113}},
+			{Region: bd.AreaEntity{ID: 2019, Name: "Московская область", Owner: 113}, Cities: bd.Cities{{ID: 2020, Name: "Королев", Owner: 2019}}},
+			{Region: bd.AreaEntity{ID: 1261, Name: "Свердловская область", Owner: 113}, Cities: bd.Cities{{ID: 3, Name: "Екатеринбург", Owner: 1261}}},
+		}},
+	}
+}
+
+func TestMapTowns(t *testing.T) {
+	src := superjob.New("test-key")
+	src.BaseURL = fixtureServer(t).URL
+
+	towns, err := src.GetTowns(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := superjob.MapTowns(towns, testAreas())
+	expected := map[int]uint{4: 1, 25: 3, 33: 2020}
+	if len(mapping) != len(expected) {
+		t.Errorf("Result was incorrect, expected %v, got %v", expected, mapping)
+	}
+	for townID, areaID := range expected {
+		if mapping[townID] != areaID {
+			t.Errorf("Town %d mapping was incorrect, expected %d, got %d", townID, areaID, mapping[townID])
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	src := superjob.New("test-key")
+	src.BaseURL = fixtureServer(t).URL
+	src.Towns = map[int]uint{4: 1, 25: 3}
+
+	page, err := src.Search(context.Background(), vacsource.Filter{VacancyName: "go"}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Found != 2 || len(page.Items) != 2 {
+		t.Fatalf("Result was incorrect, expected %d items, got %d (found %d)", 2, len(page.Items), page.Found)
+	}
+
+	first, second := page.Items[0], page.Items[1]
+	if first.Source != superjob.SourceName || first.SourceID != "46851234" || first.ItemId != bd.ItemIDFor(superjob.SourceName, "46851234") {
+		t.Errorf("Source ids were incorrect: %s %s %d", first.Source, first.SourceID, first.ItemId)
+	}
+	if first.Area != 1 || first.Schedule != "fullDay" || first.Expierence != "between3And6" || first.SalaryCurrency != "RUR" {
+		t.Errorf("First vacancy was incorrect: %+v", first)
+	}
+	if second.Area != 3 || second.Schedule != "remote" || second.Expierence != "noExperience" || second.Company != "Лютик" || second.SalaryCurrency != "UZS" {
+		t.Errorf("Second vacancy was incorrect: %+v", second)
+	}
+}
+
+// Ни один город SuperJob не попал в локацию: town[] не передается, чужие города отсеиваются по выдаче
+func TestSearchOutsideLocation(t *testing.T) {
+	src := superjob.New("test-key")
+	src.BaseURL = fixtureServer(t).URL
+	src.Towns = map[int]uint{4: 1, 25: 3}
+	src.Areas = testAreas()
+
+	page, err := src.Search(context.Background(), vacsource.Filter{VacancyName: "go", Location: 2019}, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 0 {
+		t.Errorf("Result was incorrect, expected no items outside location, got %+v", page.Items)
+	}
+}
+
+func TestCatalogues(t *testing.T) {
+	src := superjob.New("test-key")
+	src.BaseURL = fixtureServer(t).URL
+
+	catalogues, err := src.GetCatalogues(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted := superjob.ConvertCatalogues(catalogues); len(converted) != 3 || converted[1].ParentID != "33" {
+		t.Errorf("Result was incorrect: %+v", converted)
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	src := superjob.New("wrong-key")
+	src.BaseURL = fixtureServer(t).URL
+
+	if _, err := src.Search(context.Background(), vacsource.Filter{VacancyName: "go"}, 10, 0); err == nil {
+		t.Error("Expected error on forbidden response")
+	}
+}
@@ -0,0 +1,166 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+	"vacancydealer/vacsource"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	carouselRefreshSize = 20 // вакансий с каждого источника при открытии карусели
+	// callbackData счетчика страниц: нажатие только подтверждается, сообщение не меняется
+	carouselNoop = "?car:noop"
+)
+
+// Карусель вакансий подписки: одно сообщение, листается на месте.
+// Свежая выдача источников сохраняется в БД, дальше листаются все подходящие вакансии из БД
+func openCarousel(ctx context.Context, tgID int64, s bd.Subscription, b *bot.Bot) (err error) {
+	refreshFromSources(ctx, tgID, s)
+
+	text, buttons, err := carouselPage(ctx, s, 0)
+	if err != nil {
+		return
+	}
+	if text == "" {
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      tgID,
+			ParseMode:   models.ParseModeHTML,
+			Text:        "<b>Нет результатов запроса</b>\nпопробуйте изменить параметры поиска",
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"« к поиску", subCallback("subShow", s.ID)}})},
+		})
+		return
+	}
+
+	if _, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	}); err != nil {
+		err = fmt.Errorf("subscription %d carousel show error: %w", s.ID, err)
+	}
+	return
+}
+
+// Первая страница живого поиска каждого источника в БД - карусель начинается со свежих вакансий
+func refreshFromSources(ctx context.Context, tgID int64, s bd.Subscription) {
+	ex, err := bd.GetUserExclusions(tgID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	for _, src := range vacsource.List() {
+		res, err := src.Search(ctx, vacsource.FilterFromSubscription(s, ex), carouselRefreshSize, 0)
+		if err != nil {
+			logger.Error(err.Error())
+			continue
+		}
+		if res.Items, err = res.Items.Deduplicate(ctx); err != nil {
+			logger.Error(err.Error())
+		} else if err = res.Items.SaveInDB(ctx); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
+
+// Страница карусели: карточка вакансии, ее действия и листание. Пустой текст - вакансий нет
+func carouselPage(ctx context.Context, s bd.Subscription, page int) (text string, buttons [][]models.InlineKeyboardButton, err error) {
+	announces, total, err := s.BrowseJobAnnounces(ctx, Areas, page, 1)
+	if err != nil || len(announces) == 0 {
+		return
+	}
+	cur, err := bd.GetCurrencies()
+	if err != nil {
+		return
+	}
+
+	ranked := s.Rank(announces, Areas, cur, 1, time.Now())
+	ja := convertSalaries(attachDuplicateLinks(convertJobDataModelDBtoTG(announces, Areas)), s.SalaryCurrency)[0]
+	ja.Score, ja.ScoreReasons = ranked[0].Score.Score, ranked[0].Score.Reasons
+
+	var nav []models.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, models.InlineKeyboardButton{Text: "◀", CallbackData: carouselCallback(s.ID, page-1)})
+	}
+	nav = append(nav, models.InlineKeyboardButton{Text: fmt.Sprintf("%d / %d", page+1, total), CallbackData: carouselNoop})
+	if int64(page+1) < total {
+		nav = append(nav, models.InlineKeyboardButton{Text: "▶", CallbackData: carouselCallback(s.ID, page+1)})
+	}
+
+	buttons = append(ja.linkButtons(), nav, []models.InlineKeyboardButton{{Text: "« к поиску", CallbackData: subCallback("subShow", s.ID)}})
+	return ja.cardText(), buttons, nil
+}
+
+// callbackData страницы карусели
+func carouselCallback(subID uint, page int) string {
+	return fmt.Sprintf("?car:%d:%d", subID, page)
+}
+
+// carousel page handler: "?car:<ИД подписки>:<страница>", сообщение переписывается на месте
+func carouselPager(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	if _, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID}); err != nil {
+		logger.Error(fmt.Errorf("user %d carousel callback answering error: %w", tgUID, err).Error())
+	}
+	if update.CallbackQuery.Data == carouselNoop {
+		return
+	}
+
+	subArg, pageArg, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?car:"), ":")
+	subID, err := strconv.ParseUint(subArg, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of subscription id parsing error: %w", err).Error())
+		return
+	}
+	page, err := strconv.Atoi(pageArg)
+	if err == nil && page < 0 {
+		err = fmt.Errorf("negative page %d", page)
+	}
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of carousel page parsing error: %w", err).Error())
+		return
+	}
+
+	s, err := bd.FindSubscription(tgUID, uint(subID))
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	text, buttons, err := carouselPage(ctx, s, page)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	// вакансий стало меньше, например после скрытия работодателя - к первой странице
+	if text == "" && page > 0 {
+		if text, buttons, err = carouselPage(ctx, s, 0); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+	}
+	if text == "" {
+		return
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      tgUID,
+		MessageID:   callbackMessageID(update),
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+	if err != nil {
+		logger.Error(fmt.Errorf("subscription %d carousel page editing error: %w", s.ID, err).Error())
+	}
+}
@@ -0,0 +1,143 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Команды бота в меню Telegram
+var botCommands = []models.BotCommand{
+	{Command: "start", Description: "начать работу с ботом"},
+	{Command: "filters", Description: "мои поиски и их фильтры"},
+	{Command: "saved", Description: "избранные вакансии"},
+	{Command: "pause", Description: "приостановить рассылку"},
+	{Command: "resume", Description: "возобновить рассылку"},
+	{Command: "stop", Description: "отписаться и удалить мои данные"},
+	{Command: "help", Description: "справка"},
+}
+
+const helpText = `<b>Бот присылает новые вакансии по сохраненным поискам</b>
+
+/filters - мои поиски: профессия, регион, опыт, график, зарплата
+/saved - избранные вакансии с заметками
+/pause - приостановить рассылку, поиски сохранятся
+/resume - возобновить рассылку
+/stop - отписаться и удалить все мои данные
+/help - эта справка
+
+В запросе профессии можно использовать AND, OR, NOT, скобки, фразы в кавычках и поля name:, description:, company:
+ <u>пример:</u> golang AND (backend OR "api developer") NOT 1С
+
+В меню поисков настраиваются исключения, доставка дайджестом, часовой пояс и тихие часы.`
+
+// Регистрация команд в меню Telegram
+func setCommands(ctx context.Context, b *bot.Bot) (err error) {
+	if _, err = b.SetMyCommands(ctx, &bot.SetMyCommandsParams{Commands: botCommands}); err != nil {
+		err = fmt.Errorf("bot commands setting error: %w", err)
+	}
+	return
+}
+
+// Command handler: "/<команда>[@<бот>] [аргументы]"
+func commandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.Message.From.ID
+
+	command, _, _ := strings.Cut(strings.TrimPrefix(update.Message.Text, "/"), " ")
+	command, _, _ = strings.Cut(command, "@")
+
+	// команда отменяет ожидание ввода
+	_, _, err := States.Take(ctx, tgUID)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	switch strings.ToLower(command) {
+	case "start":
+		err = startCommand(ctx, tgUID, b)
+	case "filters":
+		err = sentUserDataToClient(ctx, tgUID, b)
+	case "saved":
+		err = sentSavedToClient(ctx, tgUID, b)
+	case "pause":
+		err = setUserActive(ctx, tgUID, false, b)
+	case "resume":
+		err = setUserActive(ctx, tgUID, true, b)
+	case "stop":
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      tgUID,
+			ParseMode:   models.ParseModeHTML,
+			Text:        "<b>Удалить мои данные?</b>\n\nВсе поиски, исключения, избранное и история рассылки будут удалены, рассылка прекратится.",
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"да, удалить", "#stopYes"}, {"нет", "#mySubs"}})},
+		})
+	default:
+		err = sentHelpToClient(ctx, tgUID, b)
+	}
+	if err != nil {
+		logger.Error(fmt.Errorf("command %q of user %d error: %w", command, tgUID, err).Error())
+	}
+}
+
+func startCommand(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+
+	text := "<b>Привет!</b>\n\nЯ ищу вакансии по вашим поискам и присылаю новые. Справка - /help"
+	if !ud.Active {
+		text += "\n\nРассылка сейчас приостановлена, возобновить - /resume"
+	}
+	if ud.OnboardingStep != uint8(onboardingDone) {
+		text += "\n\nДавайте настроим первый поиск - это займет минуту."
+	}
+	if _, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: tgID, ParseMode: models.ParseModeHTML, Text: text}); err != nil {
+		return
+	}
+	return sentHomeToClient(ctx, tgID, b)
+}
+
+func sentHelpToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    tgID,
+		ParseMode: models.ParseModeHTML,
+		Text:      helpText,
+	})
+	return
+}
+
+// Приостановка или возобновление рассылки
+func setUserActive(ctx context.Context, tgID int64, active bool, b *bot.Bot) (err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+	if err = ud.SetActive(active); err != nil {
+		return
+	}
+
+	text := "<b>Рассылка приостановлена</b>\n\nПоиски сохранены, возобновить - /resume"
+	if active {
+		text = "<b>Рассылка возобновлена</b>\n\nНовые вакансии снова будут приходить."
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{ChatID: tgID, ParseMode: models.ParseModeHTML, Text: text})
+	return
+}
+
+// Удаление данных пользователя после подтверждения /stop
+func stopConfirmed(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	if err = bd.DeleteUserData(ctx, tgID); err != nil {
+		return
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    tgID,
+		ParseMode: models.ParseModeHTML,
+		Text:      "<b>Данные удалены</b>\n\nРассылка прекращена. Чтобы начать заново - /start",
+	})
+	return
+}
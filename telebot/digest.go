@@ -0,0 +1,243 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const digestPageSize = 10 // вакансий на странице дайджеста
+
+// часы ежедневного дайджеста на выбор
+var digestHours = []int{7, 8, 9, 10, 12, 14, 18, 20}
+
+var deliveryModeNames = map[string]string{
+	bd.DeliveryModeInstant: "сразу, каждая вакансия отдельно",
+	bd.DeliveryModeHourly:  "дайджест раз в час",
+	bd.DeliveryModeDaily:   "дайджест раз в день",
+}
+
+// Дайджесты пользователям, у которых подошло время и есть ожидающие вакансии.
+// localNow - текущее время в часовом поясе пользователя
+func sendDigests(ctx context.Context, b *bot.Bot, users bd.UserDataList, localNow func(tgID int64) time.Time) {
+	for _, u := range users {
+		now := localNow(u.TgID)
+		if !u.Active || !u.DigestDue(now) {
+			continue
+		}
+
+		items, total, err := bd.GetDigestItems(ctx, uint(u.TgID), 0, 0, digestPageSize)
+		if err != nil {
+			logger.Error(err.Error())
+			continue
+		}
+		if total == 0 {
+			continue
+		}
+
+		msg, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:             u.TgID,
+			ParseMode:          models.ParseModeHTML,
+			Text:               digestText(items, 0, total),
+			LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: bot.True()},
+			ReplyMarkup:        &models.InlineKeyboardMarkup{InlineKeyboard: digestButtons(0, total)},
+		})
+		if err != nil {
+			logger.Error(fmt.Errorf("user %d digest sending error: %w", u.TgID, err).Error())
+			if botBlocked(err) {
+				pauseBlockedUser(u)
+			}
+			continue
+		}
+
+		if err = bd.MarkDigestSent(ctx, uint(u.TgID), msg.ID, now); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
+
+// Страница дайджеста: по строке на вакансию со ссылкой
+func digestText(items []bd.DigestItem, page int, total int64) string {
+	text := fmt.Sprintf("<b> <u>Новые вакансии: %d</u> </b>", total)
+	if pages := digestPages(total); pages > 1 {
+		text += fmt.Sprintf("  <i>стр. %d/%d</i>", page+1, pages)
+	}
+	text += "\n"
+
+	for i, item := range items {
+		text += fmt.Sprintf("\n<b>%d.</b> ", page*digestPageSize+i+1)
+		if item.Score != 0 {
+			text += fmt.Sprintf("⭐ %d ", item.Score)
+		}
+		text += fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(item.Link), html.EscapeString(item.Name))
+
+		details := make([]string, 0, 2)
+		if item.Company != "" {
+			details = append(details, html.EscapeString(item.Company))
+		}
+		if salary := salaryRange(item.SalaryFrom, item.SalaryTo, item.SalaryCurrency); salary != "" {
+			details = append(details, salary)
+		}
+		if len(details) != 0 {
+			text += "\n<i>" + strings.Join(details, ", ") + "</i>"
+		}
+	}
+	return text
+}
+
+// Листание дайджеста: "?digest:<страница>"
+func digestButtons(page int, total int64) [][]models.InlineKeyboardButton {
+	var row []models.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, models.InlineKeyboardButton{Text: "◀ назад", CallbackData: fmt.Sprintf("?digest:%d", page-1)})
+	}
+	if page+1 < digestPages(total) {
+		row = append(row, models.InlineKeyboardButton{Text: "ещё ▶", CallbackData: fmt.Sprintf("?digest:%d", page+1)})
+	}
+	if len(row) == 0 {
+		return [][]models.InlineKeyboardButton{}
+	}
+	return [][]models.InlineKeyboardButton{row}
+}
+
+func digestPages(total int64) int {
+	return int((total + digestPageSize - 1) / digestPageSize)
+}
+
+func salaryRange(from, to float64, currency string) string {
+	switch {
+	case from > 0 && to > 0:
+		return fmt.Sprintf("%.0f - %.0f %s", from, to, currency)
+	case from > 0:
+		return fmt.Sprintf("от %.0f %s", from, currency)
+	case to > 0:
+		return fmt.Sprintf("до %.0f %s", to, currency)
+	}
+	return ""
+}
+
+// digest page handler: "?digest:<страница>", сообщение дайджеста переписывается на месте
+func digestPager(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	page, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, "?digest:"))
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of digest page parsing error: %w", err).Error())
+		return
+	}
+	messageID := callbackMessageID(update)
+
+	items, total, err := bd.GetDigestItems(ctx, uint(tgUID), messageID, page*digestPageSize, digestPageSize)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:             tgUID,
+		MessageID:          messageID,
+		ParseMode:          models.ParseModeHTML,
+		Text:               digestText(items, page, total),
+		LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: bot.True()},
+		ReplyMarkup:        &models.InlineKeyboardMarkup{InlineKeyboard: digestButtons(page, total)},
+	})
+	if err != nil {
+		logger.Error(fmt.Errorf("user %d digest page editing error: %w", tgUID, err).Error())
+	}
+}
+
+// ИД сообщения, под которым нажата кнопка
+func callbackMessageID(update *models.Update) int {
+	m := update.CallbackQuery.Message
+	if m.Message != nil {
+		return m.Message.ID
+	}
+	if m.InaccessibleMessage != nil {
+		return m.InaccessibleMessage.MessageID
+	}
+	return 0
+}
+
+// Настройки доставки вакансий
+func sentDeliveryMenuToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	params, err := deliveryPrompt(tgID)
+	if err != nil {
+		return
+	}
+
+	if _, err = b.SendMessage(ctx, appendButtons(params, linesButtonGenerate([][2]string{{"« все поиски", "#mySubs"}})...)); err != nil {
+		err = fmt.Errorf("delivery menu show error: %w", err)
+		return
+	}
+	return nil
+}
+
+func deliveryPrompt(tgID int64) (params *bot.SendMessageParams, err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+
+	mode := deliveryModeNames[ud.DeliveryMode]
+	if ud.DeliveryMode == bd.DeliveryModeDaily {
+		mode += fmt.Sprintf(" в %02d:00", ud.DigestHour)
+	}
+
+	buttonsData := [][2]string{
+		{"сразу", "?delivery:" + bd.DeliveryModeInstant},
+		{"раз в час", "?delivery:" + bd.DeliveryModeHourly},
+	}
+	for _, h := range digestHours {
+		buttonsData = append(buttonsData, [2]string{fmt.Sprintf("раз в день в %02d:00", h), fmt.Sprintf("?delivery:%s:%d", bd.DeliveryModeDaily, h)})
+	}
+
+	return &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        fmt.Sprintf("<b> <u>Доставка вакансий</u> </b>\n\n<b>Сейчас: </b><i> %s</i>\n\nДайджест приходит одним сообщением со списком новых вакансий.", mode),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
+	}, nil
+}
+
+// delivery mode handler: "?delivery:<режим>[:<час дайджеста>]"
+func deliveryModeSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	mode, hourArg, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?delivery:"), ":")
+	if _, ok := deliveryModeNames[mode]; !ok {
+		logger.Error(fmt.Sprintf("unknown delivery mode %q", mode))
+		return
+	}
+
+	ud, err := bd.FindOrCreateUser(tgUID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	hour := ud.DigestHour
+	if hourArg != "" {
+		if hour, err = strconv.Atoi(hourArg); err != nil {
+			logger.Error(fmt.Errorf("incomming callbackData of digest hour parsing error: %w", err).Error())
+			return
+		}
+	}
+
+	if err = ud.SetDeliveryMode(mode, hour); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = afterChange(ctx, tgUID, onboardingDelivery, b, func() error { return sentDeliveryMenuToClient(ctx, tgUID, b) }); err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -3,12 +3,12 @@ package telebot
 import (
 	"context"
 	"fmt"
+	"html"
 	"strconv"
 	"strings"
-	"time"
 	"vacancydealer/bd"
-	"vacancydealer/hh"
 	"vacancydealer/logger"
+	"vacancydealer/querylang"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -21,22 +21,42 @@ func textHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 
 	switch update.Message.Text {
 	default:
-		if u, ok := UserStates[tgUID]; ok {
-			defer delete(UserStates, tgUID)
-
+		u, ok, err := States.Take(ctx, tgUID)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		if ok && u.State.Valid() {
 			switch u.State {
-			case 1:
-				u.User.Vacancy = update.Message.Text
-				if err := u.User.convertUserModelTGtoDB().Update(); err != nil {
+			case StateVacancyName:
+				if _, err := querylang.Parse(update.Message.Text); err != nil {
+					_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+						ChatID:      tgUID,
+						ParseMode:   models.ParseModeHTML,
+						Text:        "<b>Ошибка в запросе</b>\n\n" + html.EscapeString(err.Error()),
+						ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"ввести заново", subCallback("subName", u.SubID)}})},
+					})
+					if err != nil {
+						logger.Error(err.Error())
+					}
+					return
+				}
+				s, err := bd.FindSubscription(tgUID, u.SubID)
+				if err != nil {
+					logger.Error(err.Error())
+					return
+				}
+				s.VacancyName = update.Message.Text
+				if err := s.UpdateFilter(); err != nil {
 					logger.Error(err.Error())
 					return
 				}
 
-				if err := sentUserDataToClient(ctx, tgUID, b); err != nil {
+				if err := afterChange(ctx, tgUID, onboardingProfession, b, func() error { return sentSubscriptionToClient(ctx, tgUID, s.ID, b) }); err != nil {
 					logger.Error(err.Error())
 					return
 				}
-			case 21:
+			case StateCity:
 				cities, err := bd.FindCitiesByName(update.Message.Text)
 				if err != nil {
 					logger.Error(err.Error())
@@ -44,25 +64,13 @@ func textHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 
 				buttonsData := make([][2]string, 0)
 				for _, city := range cities {
-					buttonsData = append(buttonsData, [2]string{city.Name, "?setLocation:" + strconv.Itoa(int(city.ID))})
+					buttonsData = append(buttonsData, [2]string{city.Name, locationCallback(u.SubID, city.ID)})
 				}
 
-				msgParams := &bot.SendMessageParams{
-					ChatID:    tgUID,
-					ParseMode: models.ParseModeHTML,
-				}
-				if len(buttonsData) != 0 && len(buttonsData) < 30 {
-					msgParams.Text = "<b>Уточним локацию</b>\n\nНажми нужную кнопку."
-					msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)}
-				} else {
-					msgParams.Text = "<b>Уточним локацию</b>\n\nНет результатов, пожалуйста уточните название населенного пункта."
-					msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "не имеет значения", CallbackData: "?setLocation:0"}}}}
-				}
-				_, err = b.SendMessage(ctx, msgParams)
-				if err != nil {
+				if _, err = b.SendMessage(ctx, locationChoiceParams(tgUID, u.SubID, buttonsData)); err != nil {
 					logger.Error(err.Error())
 				}
-			case 22:
+			case StateRegion:
 				regions, err := bd.FindRegionByName(update.Message.Text)
 				if err != nil {
 					logger.Error(err.Error())
@@ -70,44 +78,108 @@ func textHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 
 				buttonsData := make([][2]string, 0)
 				for _, city := range regions {
-					buttonsData = append(buttonsData, [2]string{city.Name, "?setLocation:" + strconv.Itoa(int(city.ID))})
+					buttonsData = append(buttonsData, [2]string{city.Name, locationCallback(u.SubID, city.ID)})
+				}
+
+				if _, err = b.SendMessage(ctx, locationChoiceParams(tgUID, u.SubID, buttonsData)); err != nil {
+					logger.Error(err.Error())
+				}
+			case StateExperience:
+				exp, ok := parseCount(update.Message.Text)
+				if !ok {
+					retryInput(ctx, tgUID, u, "<b>Опыт работы - целое число лет</b>\n\nнапример: <i>3</i>. Введите еще раз", b)
+					return
+				}
+				s, err := bd.FindSubscription(tgUID, u.SubID)
+				if err != nil {
+					logger.Error(err.Error())
+					return
+				}
+				s.ExperienceYear = exp
+				if err := s.UpdateFilter(); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+
+				if err := afterChange(ctx, tgUID, onboardingExperience, b, func() error { return sentSubscriptionToClient(ctx, tgUID, s.ID, b) }); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+			case StateSalaryMin:
+				salary, ok := parseCount(update.Message.Text)
+				if !ok {
+					retryInput(ctx, tgUID, u, "<b>Зарплата - целое число</b>\n\nнапример: <i>150 000</i>. Введите еще раз", b)
+					return
+				}
+				s, err := bd.FindSubscription(tgUID, u.SubID)
+				if err != nil {
+					logger.Error(err.Error())
+					return
+				}
+				s.SalaryMin = salary
+				if err := s.UpdateFilter(); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+
+				if err := afterChange(ctx, tgUID, onboardingSalary, b, func() error { return sentSalaryMenuToClient(ctx, tgUID, s.ID, b) }); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+			case StateExcludeWords:
+				ud, err := bd.FindOrCreateUser(tgUID)
+				if err != nil {
+					logger.Error(err.Error())
+					return
+				}
+				if err = ud.UpdateExcludeWords(bd.ParseExcludeWords(strings.TrimPrefix(update.Message.Text, "-"))); err != nil {
+					logger.Error(err.Error())
+					return
 				}
 
-				msgParams := &bot.SendMessageParams{
-					ChatID:    tgUID,
-					ParseMode: models.ParseModeHTML,
+				if err := sentExclusionsToClient(ctx, tgUID, b); err != nil {
+					logger.Error(err.Error())
+					return
 				}
-				if len(buttonsData) != 0 && len(buttonsData) < 30 {
-					msgParams.Text = "<b>Уточним локацию</b>\n\nНажми нужную кнопку."
-					msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)}
-				} else {
-					msgParams.Text = "<b>Уточним локацию</b>\n\nНет результатов, пожалуйста уточните название населенного пункта."
-					msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "не имеет значения", CallbackData: "?setLocation:0"}}}}
+			case StateSavedNote:
+				if err = bd.SetSavedNote(ctx, tgUID, u.ItemID, savedNote(update.Message.Text)); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+
+				if err := sentSavedToClient(ctx, tgUID, b); err != nil {
+					logger.Error(err.Error())
+					return
 				}
-				_, err = b.SendMessage(ctx, msgParams)
+			case StateNewSubName:
+				s, err := bd.CreateSubscription(tgUID, update.Message.Text)
 				if err != nil {
 					logger.Error(err.Error())
+					return
 				}
-			case 3:
 
-				exp, err := strconv.Atoi(update.Message.Text)
+				if err := sentSubscriptionToClient(ctx, tgUID, s.ID, b); err != nil {
+					logger.Error(err.Error())
+					return
+				}
+			case StateRename:
+				s, err := bd.FindSubscription(tgUID, u.SubID)
 				if err != nil {
-					logger.Error(fmt.Errorf("input experience value parsing error: %w", err).Error())
+					logger.Error(err.Error())
 					return
 				}
-				u.User.ExperienceYears = exp
-				if err := u.User.convertUserModelTGtoDB().Update(); err != nil {
+				if err = s.Rename(update.Message.Text); err != nil {
 					logger.Error(err.Error())
 					return
 				}
 
-				if err := sentUserDataToClient(ctx, tgUID, b); err != nil {
+				if err := sentSubscriptionToClient(ctx, tgUID, s.ID, b); err != nil {
 					logger.Error(err.Error())
 					return
 				}
 			}
 		} else {
-			if err := sentUserDataToClient(ctx, tgUID, b); err != nil {
+			if err := sentHomeToClient(ctx, tgUID, b); err != nil {
 				logger.Error(err.Error())
 				return
 			}
@@ -121,82 +193,172 @@ func callbackProcessing(ctx context.Context, b *bot.Bot, update *models.Update)
 	tgUID := update.CallbackQuery.From.ID
 
 	switch update.CallbackQuery.Data {
-	case "#vacFilterWritePlease":
+	case "#mySubs":
+		if err := sentUserDataToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#newSub":
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      tgUID,
-			ParseMode:   models.ParseModeHTML,
-			Text:        "<b>Что изменим?</b>\n\nНажми нужную кнопку.",
-			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"профессия", "#changeVacancyName"}, {"регион", "#changeLocation"}, {"опыт работы", "#changeExperience"}, {"график работы", "#changeSchedule"}})},
+			ChatID:    tgUID,
+			ParseMode: models.ParseModeHTML,
+			Text:      "<b>Новый поиск</b>\n\nВведите название поиска, например: <i>golang удаленно</i>",
 		})
 		if err != nil {
-			logger.Error(fmt.Errorf("filter write command handler error^ %w", err).Error())
+			logger.Error(fmt.Errorf("new subscription function, to user %d have a error: %w", tgUID, err).Error())
+			return
+		}
+
+		awaitInput(ctx, tgUID, StateNewSubName, 0)
+	case "#exclusions":
+		if err := sentExclusionsToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#delivery":
+		if err := sentDeliveryMenuToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#saved":
+		if err := sentSavedToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#stopYes":
+		if err := stopConfirmed(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#timeSettings":
+		if err := sentTimeSettingsToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "#timezones":
+		if err := sentTimezonesToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
 		}
-	case "#changeVacancyName":
+	case "#excludeWords":
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    tgUID,
 			ParseMode: models.ParseModeHTML,
-			Text:      "<b>назвние вакансии</b>\n\nНазвание вакансии не обязательно должно быть полным. Поиск происходит по совпадению ключевых слов в названии вакансии. Допустимо указать одно слово в вакансии или более. Важно понимать, что работодатель указывает произвольное название.\n\nвведи ключевое слово для поиска по названию вакансии"},
-		)
+			Text:      "<b>Стоп-слова</b>\n\nВакансии, в названии или описании которых есть стоп-слово, не показываются. Введите слова или фразы через запятую\n <u>пример:</u> 1С, senior lead, стажер\n\nчтобы очистить список, отправьте -",
+		})
 		if err != nil {
-			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
+			logger.Error(fmt.Errorf("exclude words function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
 
-		state := UserStateData{State: 1, Date: time.Now()}
-		state.User, err = findRegisterUser(tgUID)
-		if err != nil {
+		awaitInput(ctx, tgUID, StateExcludeWords, 0)
+	}
+
+}
+
+// Subscription callback handler: "?<action>:<ИД подписки>[:<значение>]"
+func subscriptionCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	action, args, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?"), ":")
+	subArg, value, _ := strings.Cut(args, ":")
+	subID, err := strconv.ParseUint(subArg, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of subscription id parsing error: %w", err).Error())
+		return
+	}
+
+	s, err := bd.FindSubscription(tgUID, uint(subID))
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	switch action {
+	case "subShow":
+		if err = sentSubscriptionToClient(ctx, tgUID, s.ID, b); err != nil {
 			logger.Error(err.Error())
-			return
 		}
-		UserStates[tgUID] = state
-	case "#changeLocation":
+	case "subEdit":
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      tgUID,
 			ParseMode:   models.ParseModeHTML,
-			Text:        "<b>Замена региона поиска вакансии</b>\n\nУточнить локацию поиска до:",
-			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"страны", "#changeCountry"}, {"региона", "#changeRegion"}, {"населенного пункта", "#changeCity"}, {"не имеет значения", "?setLocation:0"}})},
+			Text:        "<b>Что изменим?</b>\n\nНажми нужную кнопку.",
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"профессия", subCallback("subName", s.ID)}, {"регион", subCallback("subLoc", s.ID)}, {"опыт работы", subCallback("subExp", s.ID)}, {"график работы", subCallback("subSched", s.ID)}, {"зарплата", subCallback("subSalary", s.ID)}})},
 		})
 		if err != nil {
-			logger.Error(fmt.Errorf("change city name function, to user %d have a error: %w", tgUID, err).Error())
+			logger.Error(fmt.Errorf("filter write command handler error^ %w", err).Error())
+		}
+	case "subToggle":
+		if err = s.SetActive(!s.Active); err != nil {
+			logger.Error(err.Error())
 			return
 		}
-	case "#changeCity":
+		if err = sentSubscriptionToClient(ctx, tgUID, s.ID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "subRename":
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    tgUID,
 			ParseMode: models.ParseModeHTML,
-			Text:      "<b>Укажите населенный пункт</b>\n\nВведите название населенного пункта:",
+			Text:      "<b>Название поиска</b>\n\nВведите новое название поиска:",
 		})
 		if err != nil {
-			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
+			logger.Error(fmt.Errorf("rename subscription function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
 
-		state := UserStateData{State: 21, Date: time.Now()}
-		state.User, err = findRegisterUser(tgUID)
+		awaitInput(ctx, tgUID, StateRename, s.ID)
+	case "subDel":
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      tgUID,
+			ParseMode:   models.ParseModeHTML,
+			Text:        "<b>Удалить поиск?</b>\n\nРассылка по нему прекратится.",
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"да, удалить", subCallback("subDelYes", s.ID)}, {"нет", subCallback("subShow", s.ID)}})},
+		})
 		if err != nil {
+			logger.Error(fmt.Errorf("delete subscription function, to user %d have a error: %w", tgUID, err).Error())
+		}
+	case "subDelYes":
+		if err = bd.DeleteSubscription(tgUID, s.ID); err != nil {
 			logger.Error(err.Error())
 			return
 		}
-		UserStates[tgUID] = state
-	case "#changeRegion":
+		if err = sentUserDataToClient(ctx, tgUID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "subName":
+		_, err := b.SendMessage(ctx, vacancyNamePrompt(tgUID))
+		if err != nil {
+			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
+			return
+		}
+
+		awaitInput(ctx, tgUID, StateVacancyName, s.ID)
+	case "subLoc":
+		_, err := b.SendMessage(ctx, locationPrompt(tgUID, s.ID))
+		if err != nil {
+			logger.Error(fmt.Errorf("change city name function, to user %d have a error: %w", tgUID, err).Error())
+			return
+		}
+	case "subCity":
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    tgUID,
 			ParseMode: models.ParseModeHTML,
-			Text:      "<b>Укажите регион/область</b>\n\nВведите название региона/области:",
+			Text:      "<b>Укажите населенный пункт</b>\n\nВведите название населенного пункта:",
 		})
 		if err != nil {
-			logger.Error(fmt.Errorf("change region name function, to user %d have a error: %w", tgUID, err).Error())
+			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
 
-		state := UserStateData{State: 22, Date: time.Now()}
-		state.User, err = findRegisterUser(tgUID)
+		awaitInput(ctx, tgUID, StateCity, s.ID)
+	case "subRegion":
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    tgUID,
+			ParseMode: models.ParseModeHTML,
+			Text:      "<b>Укажите регион/область</b>\n\nВведите название региона/области:",
+		})
 		if err != nil {
-			logger.Error(err.Error())
+			logger.Error(fmt.Errorf("change region name function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
-		UserStates[tgUID] = state
-	case "#changeCountry":
+
+		awaitInput(ctx, tgUID, StateRegion, s.ID)
+	case "subCountry":
 		countries, err := bd.FindCountries()
 		if err != nil {
 			logger.Error(err.Error())
@@ -204,136 +366,192 @@ func callbackProcessing(ctx context.Context, b *bot.Bot, update *models.Update)
 
 		buttonsData := make([][2]string, 0)
 		for _, city := range countries {
-			buttonsData = append(buttonsData, [2]string{city.Name, "?setLocation:" + strconv.Itoa(int(city.ID))})
+			buttonsData = append(buttonsData, [2]string{city.Name, locationCallback(s.ID, city.ID)})
 		}
 
-		msgParams := &bot.SendMessageParams{
-			ChatID:    tgUID,
-			ParseMode: models.ParseModeHTML,
+		if _, err = b.SendMessage(ctx, locationChoiceParams(tgUID, s.ID, buttonsData)); err != nil {
+			logger.Error(err.Error())
 		}
-		if len(buttonsData) != 0 && len(buttonsData) < 30 {
-			msgParams.Text = "<b>Уточним локацию</b>\n\nНажми нужную кнопку."
-			msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)}
-		} else {
-			msgParams.Text = "<b>Уточним локацию</b>\n\nНет результатов, пожалуйста уточните название населенного пункта."
-			msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "не имеет значения", CallbackData: "?setLocation:0"}}}}
+	case "subExp":
+		_, err := b.SendMessage(ctx, experiencePrompt(tgUID))
+		if err != nil {
+			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
+			return
 		}
 
-		_, err = b.SendMessage(ctx, msgParams)
+		awaitInput(ctx, tgUID, StateExperience, s.ID)
+	case "subSched":
+		params, err := schedulePrompt(tgUID, s.ID)
 		if err != nil {
 			logger.Error(err.Error())
+			return
 		}
-	case "#changeExperience":
-		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:    tgUID,
-			ParseMode: models.ParseModeHTML,
-			Text:      "<b>Опыт работы</b>\n\nУкажите в годах, Ваш опыт в искомой сфере - числом\n <u>пример:</u> 12"},
-		)
+
+		_, err = b.SendMessage(ctx, params)
 		if err != nil {
 			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
-
-		state := UserStateData{State: 3, Date: time.Now()}
-		state.User, err = findRegisterUser(tgUID)
-		if err != nil {
+	case "subSalary":
+		if err = sentSalaryMenuToClient(ctx, tgUID, s.ID, b); err != nil {
 			logger.Error(err.Error())
+		}
+	case "subSalMin":
+		_, err := b.SendMessage(ctx, salaryMinPrompt(tgUID))
+		if err != nil {
+			logger.Error(fmt.Errorf("change salary function, to user %d have a error: %w", tgUID, err).Error())
 			return
 		}
-		UserStates[tgUID] = state
-	case "#changeSchedule":
 
-		sch, err := bd.GetSchedule("")
+		awaitInput(ctx, tgUID, StateSalaryMin, s.ID)
+	case "subCurMenu":
+		currencies, err := bd.GetCurrencies()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
-		schedulesButtonsData := make([][2]string, 0)
 
-		for _, s := range sch {
-			schedulesButtonsData = append(schedulesButtonsData, [2]string{s.Name, "?changeSched:" + s.HhID})
+		buttonsData := make([][2]string, 0, len(currencies))
+		for _, cur := range currencies {
+			if cur.InUse {
+				buttonsData = append(buttonsData, [2]string{cur.Name + " (" + cur.Code + ")", fmt.Sprintf("?subCur:%d:%s", s.ID, cur.Code)})
+			}
 		}
 
 		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:      tgUID,
 			ParseMode:   models.ParseModeHTML,
-			Text:        "<b>График работы</b>\n\nВыберите график работы по искомой вакансии",
-			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(schedulesButtonsData)}},
-		)
+			Text:        "<b>Валюта зарплаты</b>\n\nВ этой валюте сравниваются и показываются зарплаты вакансий. Нажми нужную кнопку.",
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
+		})
 		if err != nil {
-			logger.Error(fmt.Errorf("change vacancy name function, to user %d have a error: %w", tgUID, err).Error())
-			return
+			logger.Error(fmt.Errorf("change currency function, to user %d have a error: %w", tgUID, err).Error())
 		}
-	case "#showLast10Vac":
-		squ, err := bd.FindOrCreateUser(tgUID)
-		if err != nil {
+	case "subCur", "subGross", "subOnlySal", "subSalReset":
+		switch action {
+		case "subCur":
+			s.SalaryCurrency = value
+		case "subGross":
+			s.SalaryGross = !s.SalaryGross
+		case "subOnlySal":
+			s.OnlyWithSalary = !s.OnlyWithSalary
+		case "subSalReset":
+			s.SalaryMin, s.OnlyWithSalary = 0, false
+		}
+		if err = s.UpdateFilter(); err != nil {
 			logger.Error(err.Error())
 			return
 		}
+		if err = sentSalaryMenuToClient(ctx, tgUID, s.ID, b); err != nil {
+			logger.Error(err.Error())
+		}
+	case "subBrowse":
+		if err = openCarousel(ctx, tgUID, s, b); err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
 
-		for _, f := range hh.ConvertUserData([]bd.UserData{squ}) {
-			res, err := f.GetVacancies(10, 0)
-			if err != nil {
-				logger.Error(err.Error())
-				return
-			}
+// hide employer handler: "?hideEmp:<ИД вакансии>"
+func employerHider(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+	itemID, err := strconv.ParseUint(strings.TrimPrefix(update.CallbackQuery.Data, "?hideEmp:"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of vacancy id parsing error: %w", err).Error())
+		return
+	}
 
-			if len(res.Items) == 0 {
-				b.SendMessage(ctx, &bot.SendMessageParams{
-					ChatID:    tgUID,
-					ParseMode: models.ParseModeHTML,
-					Text:      "<b>Нет результатов запроса</b>\nпопробуйте изменить параметры поиска",
-				})
-			}
+	a, err := bd.FindJobAnnounce(uint(itemID))
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = bd.BlockEmployer(tgUID, a.Company); err != nil {
+		logger.Error(err.Error())
+		return
+	}
 
-			for _, j := range convertAnnounceHHtoTG(res) {
-				if err = j.sentJobAnnounceToClient(ctx, tgUID, b); err != nil {
-					logger.Error(err.Error())
-					continue
-				}
-			}
-		}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgUID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        fmt.Sprintf("<b>Работодатель скрыт</b>\n\nВакансии <b>%s</b> больше не будут приходить.", html.EscapeString(a.Company)),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"исключения", "#exclusions"}})},
+	})
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// unblock employer handler: "?unblockEmp:<ИД записи>"
+func employerUnblocker(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+	id, err := strconv.ParseUint(strings.TrimPrefix(update.CallbackQuery.Data, "?unblockEmp:"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of blocked employer id parsing error: %w", err).Error())
+		return
 	}
 
+	if err = bd.UnblockEmployer(tgUID, uint(id)); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = sentExclusionsToClient(ctx, tgUID, b); err != nil {
+		logger.Error(err.Error())
+	}
 }
 
-// change location Handler
+// change location Handler: "?setLocation:<ИД подписки>:<ИД локации>"
 func locationSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
 	tgUID := update.CallbackQuery.From.ID
-	locationID, err := strconv.Atoi(strings.Trim(update.CallbackQuery.Data, "?setLocation:"))
+	subArg, locArg, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?setLocation:"), ":")
+	subID, err := strconv.ParseUint(subArg, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of subscription id parsing error: %w", err).Error())
+		return
+	}
+	locationID, err := strconv.Atoi(locArg)
 	if err != nil {
-		err = fmt.Errorf("incomming callbackData of region id parsing error: %w", err)
+		logger.Error(fmt.Errorf("incomming callbackData of region id parsing error: %w", err).Error())
+		return
 	}
-	u, err := findRegisterUser(tgUID)
+
+	s, err := bd.FindSubscription(tgUID, uint(subID))
 	if err != nil {
 		logger.Error(err.Error())
 		return
 	}
 
-	sqluser := u.convertUserModelTGtoDB()
-	sqluser.Location = uint(locationID)
-	if err = sqluser.UpdateLocation(); err != nil {
+	s.Location = uint(locationID)
+	if err = s.UpdateFilter(); err != nil {
 		logger.Error(err.Error())
 	}
 
-	sentUserDataToClient(ctx, tgUID, b)
+	if err = afterChange(ctx, tgUID, onboardingLocation, b, func() error { return sentSubscriptionToClient(ctx, tgUID, s.ID, b) }); err != nil {
+		logger.Error(err.Error())
+	}
 }
 
-// change schedule handler
+// change schedule handler: "?changeSched:<ИД подписки>:<ИД графика>"
 func scheduleSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
 	tgUID := update.CallbackQuery.From.ID
-	u, err := findRegisterUser(tgUID)
+	subArg, schedule, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?changeSched:"), ":")
+	subID, err := strconv.ParseUint(subArg, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of subscription id parsing error: %w", err).Error())
+		return
+	}
+
+	s, err := bd.FindSubscription(tgUID, uint(subID))
 	if err != nil {
 		logger.Error(err.Error())
 		return
 	}
 
-	sqluser := u.convertUserModelTGtoDB()
-	sqluser.Schedule = strings.Replace(update.CallbackQuery.Data, "?changeSched:", "", 1)
-	if err = sqluser.UpdateSchedule(); err != nil {
+	s.Schedule = schedule
+	if err = s.UpdateFilter(); err != nil {
 		logger.Error(err.Error())
 	}
-	if err = sentUserDataToClient(ctx, tgUID, b); err != nil {
+	if err = afterChange(ctx, tgUID, onboardingSchedule, b, func() error { return sentSubscriptionToClient(ctx, tgUID, s.ID, b) }); err != nil {
 		logger.Error(err.Error())
 		return
 	}
@@ -341,6 +559,63 @@ func scheduleSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
 
 // --------------------------------------------------------------------------------------<<<HANDLERS------------------------------------------------------------------------------
 
+// ------------------------------------------------------------------------->>>PROMPTS---------------------------------------------------------------
+// Вопросы для изменения фильтра подписки, общие для меню подписки и знакомства с ботом
+
+func vacancyNamePrompt(tgUID int64) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+		Text:      "<b>назвние вакансии</b>\n\nНазвание вакансии не обязательно должно быть полным. Поиск происходит по совпадению ключевых слов в названии вакансии. Допустимо указать одно слово в вакансии или более. Важно понимать, что работодатель указывает произвольное название.\n\nМожно использовать AND, OR, NOT, скобки, фразы в кавычках и поля name:, description:, company:\n <u>пример:</u> golang AND (backend OR \"api developer\") NOT 1С\n\nвведи ключевое слово для поиска по названию вакансии",
+	}
+}
+
+func locationPrompt(tgUID int64, subID uint) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		ChatID:      tgUID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        "<b>Замена региона поиска вакансии</b>\n\nУточнить локацию поиска до:",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"страны", subCallback("subCountry", subID)}, {"региона", subCallback("subRegion", subID)}, {"населенного пункта", subCallback("subCity", subID)}, {"не имеет значения", locationCallback(subID, 0)}})},
+	}
+}
+
+func experiencePrompt(tgUID int64) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+		Text:      "<b>Опыт работы</b>\n\nУкажите в годах, Ваш опыт в искомой сфере - числом\n <u>пример:</u> 12",
+	}
+}
+
+func schedulePrompt(tgUID int64, subID uint) (params *bot.SendMessageParams, err error) {
+	sch, err := bd.GetSchedule("")
+	if err != nil {
+		return
+	}
+	schedulesButtonsData := make([][2]string, 0)
+
+	for _, sc := range sch {
+		schedulesButtonsData = append(schedulesButtonsData, [2]string{sc.Name, fmt.Sprintf("?changeSched:%d:%s", subID, sc.HhID)})
+	}
+
+	return &bot.SendMessageParams{
+		ChatID:      tgUID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        "<b>График работы</b>\n\nВыберите график работы по искомой вакансии",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(schedulesButtonsData)},
+	}, nil
+}
+
+func salaryMinPrompt(tgUID int64) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+		Text:      "<b>Желаемая зарплата</b>\n\nУкажите минимальную сумму в месяц числом\n <u>пример:</u> 150000",
+	}
+}
+
+// -------------------------------------------------------------------------<<<PROMPTS---------------------------------------------------------------
+
 // ------------------------------------------------------------------------->>>BUTTON GENERATOR---------------------------------------------------------------
 func linesButtonGenerate(buttonsData [][2]string) (inlineButtons [][]models.InlineKeyboardButton) {
 	for _, lb := range buttonsData {
@@ -349,4 +624,57 @@ func linesButtonGenerate(buttonsData [][2]string) (inlineButtons [][]models.Inli
 	return
 }
 
+// Неотрицательное целое из ввода пользователя, пробелы между разрядами допускаются
+func parseCount(input string) (n int, ok bool) {
+	n, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(input), " ", ""))
+	return n, err == nil && n >= 0
+}
+
+// Неверный ввод: объяснение и снова ожидание значения в том же состоянии
+func retryInput(ctx context.Context, tgID int64, u UserStateData, text string, b *bot.Bot) {
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: tgID, ParseMode: models.ParseModeHTML, Text: text}); err != nil {
+		logger.Error(fmt.Errorf("invalid input message to user %d error: %w", tgID, err).Error())
+	}
+	if err := States.Set(ctx, tgID, u); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// Дополнительные ряды кнопок под сообщением
+func appendButtons(params *bot.SendMessageParams, rows ...[]models.InlineKeyboardButton) *bot.SendMessageParams {
+	markup, _ := params.ReplyMarkup.(*models.InlineKeyboardMarkup)
+	if markup == nil {
+		markup = &models.InlineKeyboardMarkup{}
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, rows...)
+	params.ReplyMarkup = markup
+	return params
+}
+
+// callbackData действия над подпиской
+func subCallback(action string, subID uint) string {
+	return fmt.Sprintf("?%s:%d", action, subID)
+}
+
+// callbackData выбора локации для подписки
+func locationCallback(subID, locationID uint) string {
+	return fmt.Sprintf("?setLocation:%d:%d", subID, locationID)
+}
+
+// Сообщение с вариантами локаций, либо просьба уточнить название
+func locationChoiceParams(tgUID int64, subID uint, buttonsData [][2]string) (msgParams *bot.SendMessageParams) {
+	msgParams = &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+	}
+	if len(buttonsData) != 0 && len(buttonsData) < 30 {
+		msgParams.Text = "<b>Уточним локацию</b>\n\nНажми нужную кнопку."
+		msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)}
+	} else {
+		msgParams.Text = "<b>Уточним локацию</b>\n\nНет результатов, пожалуйста уточните название населенного пункта."
+		msgParams.ReplyMarkup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "не имеет значения", CallbackData: locationCallback(subID, 0)}}}}
+	}
+	return
+}
+
 // -------------------------------------------------------------------------<<<BUTTON GENERATOR---------------------------------------------------------------
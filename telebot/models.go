@@ -3,18 +3,31 @@ package telebot
 import "time"
 
 type (
+	// Пользователь бота и его сохраненные поиски
 	UserData struct {
-		TgID            int64
+		TgID          int64
+		Subscriptions []Subscription
+	}
+
+	Subscription struct {
+		ID              uint
+		Name            string
+		Active          bool
 		Vacancy         string
 		Location        string
 		Schedule        string
 		ExperienceYears int
+		SalaryMin       int
+		SalaryCurrency  string
+		SalaryGross     bool
+		OnlyWithSalary  bool
 	}
 
 	UserStateData struct {
-		State uint8
-		User  UserData
-		Date  time.Time
+		State  State
+		SubID  uint // подписка, которую редактирует пользователь
+		ItemID uint // вакансия, к которой пишется заметка
+		Date   time.Time
 	}
 
 	ScheduleType struct {
@@ -39,5 +52,21 @@ type (
 		Requirement    string
 		Responsebility string
 		Link           string
+		Source         string
+		Employment     string
+		Address        string
+		KeySkills      string
+		Description    string
+		// ссылки на ту же вакансию в других источниках: {источник, ссылка}
+		DuplicateLinks [][2]string
+		// зарплата в валюте пользователя, если она отличается от валюты вакансии
+		SalaryConvFrom     float64
+		SalaryConvTo       float64
+		SalaryConvCurrency string
+		// название подписки, по которой найдена вакансия
+		SubscriptionName string
+		// оценка вакансии для пользователя 0..100 и ее главные причины, 0 - не оценивалась
+		Score        int
+		ScoreReasons []string
 	}
 )
@@ -0,0 +1,204 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Шаги знакомства с ботом: настройка первого поиска по одному вопросу.
+// Шаг хранится в bd.UserData.OnboardingStep - не перенумеровывать
+type onboardingStep uint8
+
+const (
+	onboardingDone onboardingStep = iota
+	onboardingProfession
+	onboardingLocation
+	onboardingExperience
+	onboardingSchedule
+	onboardingSalary
+	onboardingDelivery
+	onboardingConfirm
+)
+
+const onboardingQuestions = int(onboardingConfirm - 1) // шагов с вопросами, без подтверждения
+
+// Первый поиск пользователя, который настраивается при знакомстве
+func onboardingSubscription(tgID int64) (s bd.Subscription, err error) {
+	subs, err := bd.GetUserSubscriptions(tgID)
+	if err != nil {
+		return
+	}
+	if len(subs) == 0 {
+		return bd.CreateSubscription(tgID, bd.DefaultSubscriptionName)
+	}
+	return subs[0], nil
+}
+
+// Вопрос шага знакомства с прогрессом и кнопками назад/пропустить
+func sentOnboardingStep(ctx context.Context, tgID int64, step onboardingStep, b *bot.Bot) (err error) {
+	s, err := onboardingSubscription(tgID)
+	if err != nil {
+		return
+	}
+
+	var params *bot.SendMessageParams
+	switch step {
+	case onboardingProfession:
+		params = vacancyNamePrompt(tgID)
+		awaitInput(ctx, tgID, StateVacancyName, s.ID)
+	case onboardingLocation:
+		params = locationPrompt(tgID, s.ID)
+	case onboardingExperience:
+		params = experiencePrompt(tgID)
+		awaitInput(ctx, tgID, StateExperience, s.ID)
+	case onboardingSchedule:
+		if params, err = schedulePrompt(tgID, s.ID); err != nil {
+			return
+		}
+	case onboardingSalary:
+		params = salaryMinPrompt(tgID)
+		awaitInput(ctx, tgID, StateSalaryMin, s.ID)
+	case onboardingDelivery:
+		if params, err = deliveryPrompt(tgID); err != nil {
+			return
+		}
+	case onboardingConfirm:
+		if params, err = onboardingSummary(tgID, s); err != nil {
+			return
+		}
+	default:
+		return sentUserDataToClient(ctx, tgID, b)
+	}
+
+	params.Text = onboardingProgress(step) + params.Text
+	nav := []models.InlineKeyboardButton{{Text: "◀ назад", CallbackData: "?ob:back"}}
+	if step == onboardingConfirm {
+		nav = append(nav, models.InlineKeyboardButton{Text: "✅ всё верно", CallbackData: "?ob:done"})
+	} else {
+		nav = append(nav, models.InlineKeyboardButton{Text: "пропустить ▶", CallbackData: "?ob:skip"})
+	}
+	if step == onboardingProfession {
+		nav = nav[1:]
+	}
+
+	if _, err = b.SendMessage(ctx, appendButtons(params, nav)); err != nil {
+		err = fmt.Errorf("onboarding step %d show error: %w", step, err)
+	}
+	return
+}
+
+// Шапка шага: "Шаг 2 из 6 ●●○○○○"
+func onboardingProgress(step onboardingStep) string {
+	if step == onboardingConfirm {
+		return "<i>Проверьте настройки</i>\n\n"
+	}
+	return fmt.Sprintf("<i>Шаг %d из %d</i>  %s\n\n", step, onboardingQuestions, strings.Repeat("●", int(step))+strings.Repeat("○", onboardingQuestions-int(step)))
+}
+
+// Итог знакомства: настроенный поиск и доставка
+func onboardingSummary(tgID int64, sqls bd.Subscription) (params *bot.SendMessageParams, err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+	s := convertSubscriptionModelDBtoTG(sqls)
+
+	delivery := deliveryModeNames[ud.DeliveryMode]
+	if ud.DeliveryMode == bd.DeliveryModeDaily {
+		delivery += fmt.Sprintf(" в %02d:00", ud.DigestHour)
+	}
+
+	return &bot.SendMessageParams{
+		ChatID:    tgID,
+		ParseMode: models.ParseModeHTML,
+		Text:      fmt.Sprintf("<b> <u>Ваш поиск</u> </b>\n\n<b>Профессия: </b><i> %s</i>\n<b>Регион: </b><i> %s</i>\n<b>Опыт работы(лет): </b> %d\n<b>График работы: </b> <i> %s</i>\n<b>Зарплата: </b> <i> %s</i>\n<b>Доставка: </b> <i> %s</i>\n\nВсё можно будет изменить в меню поисков.", html.EscapeString(s.Vacancy), s.Location, s.ExperienceYears, s.Schedule, s.salaryText(), delivery),
+	}, nil
+}
+
+// Начальный экран: незаконченное знакомство с ботом или список поисков
+func sentHomeToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+	if step := onboardingStep(ud.OnboardingStep); step != onboardingDone {
+		return sentOnboardingStep(ctx, tgID, step, b)
+	}
+	return sentUserDataToClient(ctx, tgID, b)
+}
+
+// Переход к шагу знакомства с сохранением прогресса
+func gotoOnboardingStep(ctx context.Context, ud bd.UserData, step onboardingStep, b *bot.Bot) (err error) {
+	if err = ud.SetOnboardingStep(uint8(step)); err != nil {
+		return
+	}
+	return sentOnboardingStep(ctx, ud.TgID, step, b)
+}
+
+// После изменения настройки: ответ на текущий шаг знакомства с ботом ведет к следующему шагу, иначе show.
+// answered - шаг, на который отвечает изменение: правка других настроек посреди знакомства шаг не пропускает
+func afterChange(ctx context.Context, tgID int64, answered onboardingStep, b *bot.Bot, show func() error) error {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return err
+	}
+	if step := onboardingStep(ud.OnboardingStep); step != onboardingDone && step == answered && step < onboardingConfirm {
+		return gotoOnboardingStep(ctx, ud, step+1, b)
+	}
+	return show()
+}
+
+// onboarding handler: "?ob:<back|skip|done>"
+func onboardingCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	ud, err := bd.FindOrCreateUser(tgUID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	step := onboardingStep(ud.OnboardingStep)
+	if step == onboardingDone {
+		return
+	}
+
+	// ответ на пропущенный вопрос больше не ждем
+	if _, _, err = States.Take(ctx, tgUID); err != nil {
+		logger.Error(err.Error())
+	}
+
+	switch strings.TrimPrefix(update.CallbackQuery.Data, "?ob:") {
+	case "back":
+		if step > onboardingProfession {
+			step--
+		}
+		err = gotoOnboardingStep(ctx, ud, step, b)
+	case "skip":
+		if step < onboardingConfirm {
+			step++
+		}
+		err = gotoOnboardingStep(ctx, ud, step, b)
+	case "done":
+		if err = ud.SetOnboardingStep(uint8(onboardingDone)); err != nil {
+			break
+		}
+		if _, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    tgUID,
+			ParseMode: models.ParseModeHTML,
+			Text:      "<b>Готово!</b>\n\nНовые вакансии начнут приходить в ближайшее время. Справка - /help",
+		}); err != nil {
+			break
+		}
+		err = sentUserDataToClient(ctx, tgUID, b)
+	}
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+var (
+	// часовые пояса на выбор: {название, IANA}
+	timezoneChoices = [][2]string{
+		{"Калининград (МСК-1)", "Europe/Kaliningrad"},
+		{"Москва (МСК)", "Europe/Moscow"},
+		{"Самара (МСК+1)", "Europe/Samara"},
+		{"Екатеринбург (МСК+2)", "Asia/Yekaterinburg"},
+		{"Омск (МСК+3)", "Asia/Omsk"},
+		{"Новосибирск (МСК+4)", "Asia/Novosibirsk"},
+		{"Красноярск (МСК+4)", "Asia/Krasnoyarsk"},
+		{"Иркутск (МСК+5)", "Asia/Irkutsk"},
+		{"Якутск (МСК+6)", "Asia/Yakutsk"},
+		{"Владивосток (МСК+7)", "Asia/Vladivostok"},
+		{"Магадан (МСК+8)", "Asia/Magadan"},
+		{"Камчатка (МСК+9)", "Asia/Kamchatka"},
+		{"Минск", "Europe/Minsk"},
+		{"Алматы", "Asia/Almaty"},
+		{"Ташкент", "Asia/Tashkent"},
+	}
+	// тихие часы на выбор: {начало, конец}
+	quietHoursChoices = [][2]int{{22, 8}, {23, 7}, {23, 9}, {0, 8}, {21, 9}}
+)
+
+// Часовой пояс и тихие часы пользователя
+func sentTimeSettingsToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	ud, err := bd.FindOrCreateUser(tgID)
+	if err != nil {
+		return
+	}
+	subs, err := bd.GetUserSubscriptions(tgID)
+	if err != nil {
+		return
+	}
+
+	loc := ud.TimeLocation(subs, Areas)
+	timezone := loc.String()
+	if ud.Timezone == "" {
+		timezone += " (по региону поиска)"
+	}
+	quiet := "выключены"
+	if ud.QuietFrom != ud.QuietTo {
+		quiet = quietHoursText(ud.QuietFrom, ud.QuietTo)
+	}
+
+	buttonsData := [][2]string{{"сменить часовой пояс", "#timezones"}, {"без тихих часов", "?quiet:0:0"}}
+	for _, q := range quietHoursChoices {
+		buttonsData = append(buttonsData, [2]string{"тихо " + quietHoursText(q[0], q[1]), fmt.Sprintf("?quiet:%d:%d", q[0], q[1])})
+	}
+	buttonsData = append(buttonsData, [2]string{"« все поиски", "#mySubs"})
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        fmt.Sprintf("<b> <u>Время и тихие часы</u> </b>\n\n<b>Часовой пояс: </b><i> %s</i>, сейчас %s\n<b>Тихие часы: </b><i> %s</i>\n\nВ тихие часы вакансии не присылаются - они придут одним сообщением, когда тихие часы закончатся.", timezone, time.Now().In(loc).Format("15:04"), quiet),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
+	})
+	if err != nil {
+		err = fmt.Errorf("time settings show error: %w", err)
+		return
+	}
+	return nil
+}
+
+func sentTimezonesToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	buttonsData := [][2]string{{"определять по региону поиска", "?tz:"}}
+	for _, tz := range timezoneChoices {
+		buttonsData = append(buttonsData, [2]string{tz[0], "?tz:" + tz[1]})
+	}
+	buttonsData = append(buttonsData, [2]string{"« назад", "#timeSettings"})
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        "<b>Часовой пояс</b>\n\nВыберите часовой пояс:",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
+	})
+	if err != nil {
+		err = fmt.Errorf("timezones show error: %w", err)
+		return
+	}
+	return nil
+}
+
+func quietHoursText(from, to int) string {
+	return fmt.Sprintf("%02d:00 - %02d:00", from, to)
+}
+
+// timezone handler: "?tz:<IANA>", пустой - по региону поиска
+func timezoneSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	ud, err := bd.FindOrCreateUser(tgUID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = ud.SetTimezone(strings.TrimPrefix(update.CallbackQuery.Data, "?tz:")); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = sentTimeSettingsToClient(ctx, tgUID, b); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// quiet hours handler: "?quiet:<начало>:<конец>"
+func quietHoursSetter(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	fromArg, toArg, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?quiet:"), ":")
+	from, err := strconv.Atoi(fromArg)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of quiet hours start parsing error: %w", err).Error())
+		return
+	}
+	to, err := strconv.Atoi(toArg)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of quiet hours end parsing error: %w", err).Error())
+		return
+	}
+
+	ud, err := bd.FindOrCreateUser(tgUID)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = ud.SetQuietHours(from, to); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = sentTimeSettingsToClient(ctx, tgUID, b); err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,245 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+	"vacancydealer/vacsource"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	savedPageSize   = 5   // вакансий на странице избранного
+	savedNoteLength = 300 // символов в заметке
+)
+
+var savedStatusNames = map[string]string{
+	bd.SavedArchived: "🗄 вакансия в архиве",
+	bd.SavedRemoved:  "❌ вакансия удалена с источника",
+}
+
+// Избранное пользователя, первая страница новым сообщением
+func sentSavedToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	text, buttons, err := savedPage(ctx, tgID, 0)
+	if err != nil {
+		return
+	}
+
+	if _, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:             tgID,
+		ParseMode:          models.ParseModeHTML,
+		Text:               text,
+		LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: bot.True()},
+		ReplyMarkup:        &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	}); err != nil {
+		err = fmt.Errorf("user %d saved vacancies show error: %w", tgID, err)
+	}
+	return
+}
+
+// Страница избранного: текст и кнопки заметок, удаления и листания.
+// Страница за пределами избранного заменяется последней
+func savedPage(ctx context.Context, tgID int64, page int) (text string, buttons [][]models.InlineKeyboardButton, err error) {
+	items, total, err := bd.GetSavedVacancies(ctx, tgID, page*savedPageSize, savedPageSize)
+	if err != nil {
+		return
+	}
+	if len(items) == 0 && total != 0 {
+		page = savedPages(total) - 1
+		if items, total, err = bd.GetSavedVacancies(ctx, tgID, page*savedPageSize, savedPageSize); err != nil {
+			return
+		}
+	}
+	if total == 0 {
+		return "<b>Избранное пусто</b>\n\nСохраняйте вакансии кнопкой «⭐ в избранное» под карточкой.", [][]models.InlineKeyboardButton{}, nil
+	}
+
+	for i, item := range items {
+		n := page*savedPageSize + i + 1
+		buttons = append(buttons, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("📝 %d", n), CallbackData: fmt.Sprintf("?savedNote:%d", item.ItemId)},
+			{Text: fmt.Sprintf("✖ %d", n), CallbackData: fmt.Sprintf("?unsave:%d:%d", item.ItemId, page)},
+		})
+	}
+
+	var nav []models.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, models.InlineKeyboardButton{Text: "◀ назад", CallbackData: fmt.Sprintf("?saved:%d", page-1)})
+	}
+	if page+1 < savedPages(total) {
+		nav = append(nav, models.InlineKeyboardButton{Text: "ещё ▶", CallbackData: fmt.Sprintf("?saved:%d", page+1)})
+	}
+	if len(nav) != 0 {
+		buttons = append(buttons, nav)
+	}
+	return savedText(items, page, total), buttons, nil
+}
+
+// Страница избранного: по вакансии со ссылкой, состоянием на источнике и заметкой
+func savedText(items []bd.SavedItem, page int, total int64) string {
+	text := fmt.Sprintf("<b> <u>Избранное: %d</u> </b>", total)
+	if pages := savedPages(total); pages > 1 {
+		text += fmt.Sprintf("  <i>стр. %d/%d</i>", page+1, pages)
+	}
+	text += "\n"
+
+	for i, item := range items {
+		text += fmt.Sprintf("\n<b>%d.</b> <a href=\"%s\">%s</a>", page*savedPageSize+i+1, html.EscapeString(item.Link), html.EscapeString(item.Name))
+
+		details := make([]string, 0, 2)
+		if item.Company != "" {
+			details = append(details, html.EscapeString(item.Company))
+		}
+		if salary := salaryRange(item.SalaryFrom, item.SalaryTo, item.SalaryCurrency); salary != "" {
+			details = append(details, salary)
+		}
+		if len(details) != 0 {
+			text += "\n<i>" + strings.Join(details, ", ") + "</i>"
+		}
+		if status, ok := savedStatusNames[item.Status]; ok {
+			text += "\n" + status
+		}
+		if item.Note != "" {
+			text += "\n📝 " + html.EscapeString(item.Note)
+		}
+		text += "\n"
+	}
+	return text
+}
+
+func savedPages(total int64) int {
+	return int((total + savedPageSize - 1) / savedPageSize)
+}
+
+// save vacancy handler: "?save:<ИД вакансии>"
+func vacancySaver(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+	itemID, err := strconv.ParseUint(strings.TrimPrefix(update.CallbackQuery.Data, "?save:"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of vacancy id parsing error: %w", err).Error())
+		return
+	}
+
+	a, err := bd.FindJobAnnounce(uint(itemID))
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = bd.SaveVacancy(ctx, tgUID, a.ItemId); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+		Text:      fmt.Sprintf("<b>Вакансия в избранном</b>\n\n<b>%s</b> сохранена, список - /saved", html.EscapeString(a.Name)),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{
+			{"📝 добавить заметку", fmt.Sprintf("?savedNote:%d", a.ItemId)},
+			{"избранное", "#saved"},
+		})},
+	})
+	if err != nil {
+		logger.Error(fmt.Errorf("vacancy %d saving message error: %w", a.ItemId, err).Error())
+	}
+}
+
+// saved page handler: "?saved:<страница>", сообщение избранного переписывается на месте
+func savedPager(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	page, err := strconv.Atoi(strings.TrimPrefix(update.CallbackQuery.Data, "?saved:"))
+	if err == nil && page < 0 {
+		err = fmt.Errorf("negative page %d", page)
+	}
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of saved page parsing error: %w", err).Error())
+		return
+	}
+
+	if err = editSavedPage(ctx, tgUID, callbackMessageID(update), page, b); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// unsave vacancy handler: "?unsave:<ИД вакансии>:<страница>"
+func vacancyUnsaver(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+
+	itemArg, pageArg, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, "?unsave:"), ":")
+	itemID, err := strconv.ParseUint(itemArg, 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of vacancy id parsing error: %w", err).Error())
+		return
+	}
+	page, err := strconv.Atoi(pageArg)
+	if err != nil || page < 0 {
+		page = 0
+	}
+
+	if err = bd.UnsaveVacancy(ctx, tgUID, uint(itemID)); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	if err = editSavedPage(ctx, tgUID, callbackMessageID(update), page, b); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+func editSavedPage(ctx context.Context, tgID int64, messageID, page int, b *bot.Bot) (err error) {
+	text, buttons, err := savedPage(ctx, tgID, page)
+	if err != nil {
+		return
+	}
+
+	if _, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:             tgID,
+		MessageID:          messageID,
+		ParseMode:          models.ParseModeHTML,
+		Text:               text,
+		LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: bot.True()},
+		ReplyMarkup:        &models.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	}); err != nil {
+		err = fmt.Errorf("user %d saved page editing error: %w", tgID, err)
+	}
+	return
+}
+
+// saved note handler: "?savedNote:<ИД вакансии>", заметка ожидается следующим сообщением
+func savedNoteRequest(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tgUID := update.CallbackQuery.From.ID
+	itemID, err := strconv.ParseUint(strings.TrimPrefix(update.CallbackQuery.Data, "?savedNote:"), 10, 64)
+	if err != nil {
+		logger.Error(fmt.Errorf("incomming callbackData of vacancy id parsing error: %w", err).Error())
+		return
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    tgUID,
+		ParseMode: models.ParseModeHTML,
+		Text:      fmt.Sprintf("<b>Заметка к вакансии</b>\n\nВведите текст заметки, до %d символов\n <u>пример:</u> откликнулся 12.05, ждать ответа HR\n\nчтобы удалить заметку, отправьте -", savedNoteLength),
+	})
+	if err != nil {
+		logger.Error(fmt.Errorf("saved note function, to user %d have a error: %w", tgUID, err).Error())
+		return
+	}
+
+	if err = States.Set(ctx, tgUID, UserStateData{State: StateSavedNote, ItemID: uint(itemID)}); err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+// Заметка из ввода пользователя: "-" удаляет заметку, длинный текст обрезается
+func savedNote(input string) string {
+	input = strings.TrimSpace(input)
+	if input == "-" {
+		return ""
+	}
+	return vacsource.Cut(input, savedNoteLength)
+}
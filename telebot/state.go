@@ -0,0 +1,185 @@
+package telebot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+	"vacancydealer/vacsource"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	stateTTL         = 10 * time.Minute // время на ввод значения
+	stateSweepPeriod = time.Minute
+)
+
+// Состояние диалога: какое значение бот ждет следующим сообщением пользователя.
+// Каждое состояние принимает одно сообщение и возвращает диалог в StateNone.
+// Значения хранятся в БД - не перенумеровывать
+type State uint8
+
+const (
+	StateNone         State = 0
+	StateVacancyName  State = 1  // запрос вакансий подписки
+	StateCity         State = 21 // поиск населенного пункта по названию
+	StateRegion       State = 22 // поиск региона по названию
+	StateExperience   State = 3  // опыт работы, лет
+	StateNewSubName   State = 4  // название новой подписки
+	StateRename       State = 5  // новое название подписки
+	StateSalaryMin    State = 6  // желаемая зарплата
+	StateExcludeWords State = 7  // стоп-слова через запятую
+	StateSavedNote    State = 8  // заметка к вакансии из избранного
+)
+
+var stateNames = map[State]string{
+	StateNone:         "none",
+	StateVacancyName:  "vacancyName",
+	StateCity:         "city",
+	StateRegion:       "region",
+	StateExperience:   "experience",
+	StateNewSubName:   "newSubName",
+	StateRename:       "rename",
+	StateSalaryMin:    "salaryMin",
+	StateExcludeWords: "excludeWords",
+	StateSavedNote:    "savedNote",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("State(%d)", uint8(s))
+}
+
+// Известное состояние, ожидающее ввода
+func (s State) Valid() bool {
+	_, ok := stateNames[s]
+	return ok && s != StateNone
+}
+
+// Хранилище состояний диалогов. Безопасно для конкурентных обработчиков
+type StateStore interface {
+	// Ожидание ввода от пользователя, прежнее состояние заменяется
+	Set(ctx context.Context, tgID int64, s UserStateData) error
+	// Состояние пользователя с удалением: одно сообщение забирает его ровно один раз.
+	// Истекшее состояние не возвращается
+	Take(ctx context.Context, tgID int64) (s UserStateData, ok bool, err error)
+	// Удаление истекших состояний, возвращает их владельцев
+	TakeExpired(ctx context.Context) (tgIDs []int64, err error)
+}
+
+// -------------------------------------------------------------------------------------->>>MEMORY STORE
+
+// Состояния в памяти процесса, теряются при перезапуске
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	now    func() time.Time
+	states map[int64]UserStateData
+}
+
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	return &MemoryStateStore{ttl: ttl, now: time.Now, states: make(map[int64]UserStateData)}
+}
+
+func (m *MemoryStateStore) Set(_ context.Context, tgID int64, s UserStateData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.Date = m.now()
+	m.states[tgID] = s
+	return nil
+}
+
+func (m *MemoryStateStore) Take(_ context.Context, tgID int64) (s UserStateData, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok = m.states[tgID]; !ok || m.expired(s) {
+		return UserStateData{}, false, nil
+	}
+	delete(m.states, tgID)
+	return s, true, nil
+}
+
+func (m *MemoryStateStore) TakeExpired(_ context.Context) (tgIDs []int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for tgID, s := range m.states {
+		if m.expired(s) {
+			tgIDs = append(tgIDs, tgID)
+			delete(m.states, tgID)
+		}
+	}
+	return
+}
+
+func (m *MemoryStateStore) expired(s UserStateData) bool {
+	return m.now().Sub(s.Date) > m.ttl
+}
+
+// -------------------------------------------------------------------------------------->>>POSTGRES STORE
+
+// Состояния в БД: переживают перезапуск, взятие состояния атомарно
+type DBStateStore struct {
+	ttl time.Duration
+}
+
+func NewDBStateStore(ttl time.Duration) *DBStateStore {
+	return &DBStateStore{ttl: ttl}
+}
+
+func (d *DBStateStore) Set(ctx context.Context, tgID int64, s UserStateData) error {
+	return bd.SaveUserState(ctx, bd.UserState{TgID: tgID, State: uint8(s.State), SubID: s.SubID, ItemID: s.ItemID, UpdatedAt: time.Now()})
+}
+
+func (d *DBStateStore) Take(ctx context.Context, tgID int64) (s UserStateData, ok bool, err error) {
+	taken, ok, err := bd.TakeUserState(ctx, tgID, time.Now().Add(-d.ttl))
+	if err != nil || !ok {
+		return
+	}
+	return UserStateData{State: State(taken.State), SubID: taken.SubID, ItemID: taken.ItemID, Date: taken.UpdatedAt}, true, nil
+}
+
+func (d *DBStateStore) TakeExpired(ctx context.Context) ([]int64, error) {
+	return bd.TakeExpiredUserStates(ctx, time.Now().Add(-d.ttl))
+}
+
+// -------------------------------------------------------------------------------------->>>EXPIRY
+
+// Сообщение об истекшем вводе пользователям, не ответившим за stateTTL.
+// Работает до отмены ctx
+func expireStates(ctx context.Context, b *bot.Bot) {
+	for vacsource.Pause(ctx, stateSweepPeriod) {
+		tgIDs, err := States.TakeExpired(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+			continue
+		}
+
+		for _, tgID := range tgIDs {
+			_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:      tgID,
+				ParseMode:   models.ParseModeHTML,
+				Text:        "<b>Время на ввод истекло</b>\n\nИзменение не сохранено, начните его заново.",
+				ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{{"« все поиски", "#mySubs"}})},
+			})
+			if err != nil {
+				logger.Error(fmt.Errorf("state timeout message to user %d error: %w", tgID, err).Error())
+			}
+		}
+	}
+}
+
+// Ожидание ввода от пользователя
+func awaitInput(ctx context.Context, tgID int64, state State, subID uint) {
+	if err := States.Set(ctx, tgID, UserStateData{State: state, SubID: subID}); err != nil {
+		logger.Error(err.Error())
+	}
+}
@@ -0,0 +1,88 @@
+package telebot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStateStoreTake(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore(time.Minute)
+
+	if _, ok, _ := store.Take(ctx, 1); ok {
+		t.Fatal("state of unknown user taken")
+	}
+
+	store.Set(ctx, 1, UserStateData{State: StateVacancyName, SubID: 7})
+	store.Set(ctx, 1, UserStateData{State: StateRename, SubID: 8})
+	s, ok, err := store.Take(ctx, 1)
+	if err != nil || !ok {
+		t.Fatalf("Take = %v, %t, %v, expected state", s, ok, err)
+	}
+	if s.State != StateRename || s.SubID != 8 || s.Date.IsZero() {
+		t.Errorf("Take = %+v, expected last set state with date", s)
+	}
+	if _, ok, _ = store.Take(ctx, 1); ok {
+		t.Error("state taken twice")
+	}
+}
+
+func TestMemoryStateStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore(time.Minute)
+	now := time.Date(2024, 10, 17, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Set(ctx, 1, UserStateData{State: StateCity})
+	now = now.Add(2 * time.Minute)
+	store.Set(ctx, 2, UserStateData{State: StateRegion})
+
+	if _, ok, _ := store.Take(ctx, 1); ok {
+		t.Error("expired state taken")
+	}
+	expired, err := store.TakeExpired(ctx)
+	if err != nil || len(expired) != 1 || expired[0] != 1 {
+		t.Errorf("TakeExpired = %v, %v, expected [1]", expired, err)
+	}
+	if expired, _ = store.TakeExpired(ctx); len(expired) != 0 {
+		t.Errorf("second TakeExpired = %v, expected none", expired)
+	}
+	if _, ok, _ := store.Take(ctx, 2); !ok {
+		t.Error("fresh state not taken")
+	}
+}
+
+func TestMemoryStateStoreConcurrentTake(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStateStore(time.Minute)
+	store.Set(ctx, 1, UserStateData{State: StateSalaryMin})
+
+	var taken atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.Take(ctx, 1); ok {
+				taken.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if taken.Load() != 1 {
+		t.Errorf("state taken %d times, expected once", taken.Load())
+	}
+}
+
+func TestStateValid(t *testing.T) {
+	if StateNone.Valid() || State(99).Valid() {
+		t.Error("none or unknown state is valid")
+	}
+	if !StateExcludeWords.Valid() || StateCity.String() != "city" {
+		t.Error("known state is not valid")
+	}
+}
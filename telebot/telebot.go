@@ -3,43 +3,75 @@ package telebot
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/signal"
+	"html"
+	"strings"
+	"sync"
 	"vacancydealer/bd"
-	"vacancydealer/hh"
 	"vacancydealer/logger"
+	"vacancydealer/vacsource"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
+const descriptionCardLength = 500 // длина описания в карточке вакансии, рун
+
 var (
-	UserStates     map[int64]UserStateData
+	States         StateStore
+	Areas          bd.Countries // дерево локаций для карточек вакансий, загружается при старте
 	SCHEDULE_TYPES = []ScheduleType{{"удаленная работа", 1}, {"полная занятость", 2}}
 )
 
 // Start tgelegram-Bot worker
-func Run(tgAPI string) (err error) {
-	UserStates = make(map[int64]UserStateData, 100)
+// Работает до отмены ctx, возвращается после завершения рассылки
+func Run(ctx context.Context, tgAPI string) (err error) {
+	States = NewDBStateStore(stateTTL)
 
-	/*d := time.Now().Add(150 * time.Second)
-	contextDuration, cancel := context.WithDeadline(context.Background(), d)*/
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
+	if Areas, err = bd.CountriesLis(); err != nil {
+		return
+	}
 
+	// обработчики проверяются по порядку: команды раньше произвольного текста
 	opts := []bot.Option{
+		bot.WithMessageTextHandler("/", bot.MatchTypePrefix, commandHandler),
 		bot.WithMessageTextHandler("", bot.MatchTypeContains, textHandler),
 		bot.WithCallbackQueryDataHandler("#", bot.MatchTypePrefix, callbackProcessing),
 		bot.WithCallbackQueryDataHandler("?setLocation:", bot.MatchTypePrefix, locationSetter),
 		bot.WithCallbackQueryDataHandler("?changeSched:", bot.MatchTypePrefix, scheduleSetter),
+		bot.WithCallbackQueryDataHandler("?sub", bot.MatchTypePrefix, subscriptionCallback),
+		bot.WithCallbackQueryDataHandler("?hideEmp:", bot.MatchTypePrefix, employerHider),
+		bot.WithCallbackQueryDataHandler("?unblockEmp:", bot.MatchTypePrefix, employerUnblocker),
+		bot.WithCallbackQueryDataHandler("?digest:", bot.MatchTypePrefix, digestPager),
+		bot.WithCallbackQueryDataHandler("?car:", bot.MatchTypePrefix, carouselPager),
+		bot.WithCallbackQueryDataHandler("?save:", bot.MatchTypePrefix, vacancySaver),
+		bot.WithCallbackQueryDataHandler("?saved:", bot.MatchTypePrefix, savedPager),
+		bot.WithCallbackQueryDataHandler("?savedNote:", bot.MatchTypePrefix, savedNoteRequest),
+		bot.WithCallbackQueryDataHandler("?unsave:", bot.MatchTypePrefix, vacancyUnsaver),
+		bot.WithCallbackQueryDataHandler("?delivery:", bot.MatchTypePrefix, deliveryModeSetter),
+		bot.WithCallbackQueryDataHandler("?tz:", bot.MatchTypePrefix, timezoneSetter),
+		bot.WithCallbackQueryDataHandler("?ob:", bot.MatchTypePrefix, onboardingCallback),
+		bot.WithCallbackQueryDataHandler("?quiet:", bot.MatchTypePrefix, quietHoursSetter),
 	}
 
 	b, err := bot.New(tgAPI, opts...)
 	if err != nil {
 		return
 	}
-	go StartWorker(ctx, b)
+	if err = setCommands(ctx, b); err != nil {
+		logger.Error(err.Error())
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		StartWorker(ctx, b)
+	}()
+	go func() {
+		defer wg.Done()
+		expireStates(ctx, b)
+	}()
 	b.Start(ctx)
+	wg.Wait()
 
 	return nil
 }
@@ -50,24 +82,42 @@ func findRegisterUser(tgID int64) (ud UserData, err error) {
 	if err != nil {
 		return
 	}
-	return convertUserModelDBtoTG(sqludata), nil
+	subs, err := bd.GetUserSubscriptions(sqludata.TgID)
+	if err != nil {
+		return
+	}
+
+	ud.TgID = sqludata.TgID
+	for _, s := range subs {
+		ud.Subscriptions = append(ud.Subscriptions, convertSubscriptionModelDBtoTG(s))
+	}
+	return
 }
 
-// UserData response to tg-chat sent
+// UserData response to tg-chat sent: список сохраненных поисков
 func sentUserDataToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
 	ud, err := findRegisterUser(tgID)
 	if err != nil {
 		return
 	}
 
+	text := "<b> <u>Мои поиски</u> </b>\n\n"
+	buttonsData := make([][2]string, 0, len(ud.Subscriptions)+1)
+	for _, s := range ud.Subscriptions {
+		text += fmt.Sprintf("%s <b>%s</b> - <i>%s, %s</i>\n", s.activityMark(), html.EscapeString(s.Name), html.EscapeString(s.Vacancy), s.Location)
+		buttonsData = append(buttonsData, [2]string{s.activityMark() + " " + s.Name, subCallback("subShow", s.ID)})
+	}
+	if len(ud.Subscriptions) == 0 {
+		text += "Сохраненных поисков нет.\n"
+	}
+	text += "\nВыберите поиск для просмотра и редактирования."
+	buttonsData = append(buttonsData, [2]string{"➕ новый поиск", "#newSub"}, [2]string{"🚫 исключения", "#exclusions"}, [2]string{"📬 доставка", "#delivery"}, [2]string{"🕒 время и тихие часы", "#timeSettings"})
+
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:    ud.TgID,
-		ParseMode: models.ParseModeHTML,
-		Text:      fmt.Sprintf("<b> <u>Поиск вакансий</u> </b>\n\n<b>Профессия: </b><i> %s</i>\n<b>Регион: </b><i> %s</i>\n<b>Опыт работы(лет): </b> %d\n<b>График работы: </b> <i> %s</i>", ud.Vacancy, ud.Location, ud.ExperienceYears, ud.Schedule),
-		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
-			{{Text: "редактировать", CallbackData: "#vacFilterWritePlease"}},
-			{{Text: "показать последние 10", CallbackData: "#showLast10Vac"}},
-		}},
+		ChatID:      ud.TgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
 	})
 	if err != nil {
 		err = fmt.Errorf("UserData show error: %w", err)
@@ -76,53 +126,240 @@ func sentUserDataToClient(ctx context.Context, tgID int64, b *bot.Bot) (err erro
 	return nil
 }
 
-// Job Announce info to client of telegramBot sent
-func (ja JobAnnounce) sentJobAnnounceToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+// Карточка подписки с фильтром и действиями над ней
+func sentSubscriptionToClient(ctx context.Context, tgID int64, subID uint, b *bot.Bot) (err error) {
+	sqls, err := bd.FindSubscription(tgID, subID)
+	if err != nil {
+		return
+	}
+	s := convertSubscriptionModelDBtoTG(sqls)
+
+	toggle := [2]string{"выключить рассылку", subCallback("subToggle", s.ID)}
+	status := "рассылка включена"
+	if !s.Active {
+		toggle[0] = "включить рассылку"
+		status = "рассылка выключена"
+	}
+
 	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:    tgID,
 		ParseMode: models.ParseModeHTML,
-		Text:      fmt.Sprintf("<b> <u>%s</u> </b>\n<i>Наниматель: </i><b>%s</b>\n<i>Локация: </i><u>%s</u>\n\n<b>Требуемый опыт: </b><i> %s</i>\n<b>Зп \"грязными\"? -  </b>%t\n<b>Размер ЗП: </b>%.2f - %.2f%s\n<b>График работы: </b>%s", ja.Name, ja.Company, ja.Area, ja.Experience, ja.SalaryGross, ja.SalaryFrom, ja.SalaryTo, ja.SalaryCurrency, ja.Schedule),
-		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
-			{{Text: "источник", URL: ja.Link}},
-		}},
+		Text:      fmt.Sprintf("<b> <u>Поиск вакансий: %s</u> </b>\n%s %s\n\n<b>Профессия: </b><i> %s</i>\n<b>Регион: </b><i> %s</i>\n<b>Опыт работы(лет): </b> %d\n<b>График работы: </b> <i> %s</i>\n<b>Зарплата: </b> <i> %s</i>", html.EscapeString(s.Name), s.activityMark(), status, html.EscapeString(s.Vacancy), s.Location, s.ExperienceYears, s.Schedule, s.salaryText()),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{
+			{"редактировать", subCallback("subEdit", s.ID)},
+			{"листать вакансии", subCallback("subBrowse", s.ID)},
+			toggle,
+			{"переименовать", subCallback("subRename", s.ID)},
+			{"удалить", subCallback("subDel", s.ID)},
+			{"« все поиски", "#mySubs"},
+		})},
 	})
 	if err != nil {
-		err = fmt.Errorf("sentJobAnnounceTo client error: %w", err)
+		err = fmt.Errorf("subscription %d show error: %w", subID, err)
 		return
 	}
 	return nil
 }
 
-// ------------------------------------->>>MODEL CONVERTERS-----------------------------------
-// User data of search, from model of package telebot to bd model convert
-func (ud UserData) convertUserModelTGtoDB() (sqluser bd.UserData) {
-	sqluser.TgID = ud.TgID
-	sqluser.VacancyName = ud.Vacancy
-	sqluser.ExperienceYear = ud.ExperienceYears
+// Настройки зарплаты подписки
+func sentSalaryMenuToClient(ctx context.Context, tgID int64, subID uint, b *bot.Bot) (err error) {
+	sqls, err := bd.FindSubscription(tgID, subID)
+	if err != nil {
+		return
+	}
+	s := convertSubscriptionModelDBtoTG(sqls)
+
+	gross := [2]string{"сумма на руки", subCallback("subGross", s.ID)}
+	if s.SalaryGross {
+		gross[0] = "сумма до вычета НДФЛ"
+	}
+	onlyWithSalary := [2]string{"только с указанной ЗП: нет", subCallback("subOnlySal", s.ID)}
+	if s.OnlyWithSalary {
+		onlyWithSalary[0] = "только с указанной ЗП: да"
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    tgID,
+		ParseMode: models.ParseModeHTML,
+		Text:      fmt.Sprintf("<b>Зарплата</b>\n\n<b>Желаемая: </b><i> %s</i>\n\nНажми нужную кнопку.", s.salaryText()),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate([][2]string{
+			{"указать сумму", subCallback("subSalMin", s.ID)},
+			{"валюта: " + s.SalaryCurrency, subCallback("subCurMenu", s.ID)},
+			gross,
+			onlyWithSalary,
+			{"не важна", subCallback("subSalReset", s.ID)},
+			{"« к поиску", subCallback("subShow", s.ID)},
+		})},
+	})
+	if err != nil {
+		err = fmt.Errorf("subscription %d salary menu show error: %w", subID, err)
+		return
+	}
+	return nil
+}
+
+// Стоп-слова и скрытые работодатели пользователя
+func sentExclusionsToClient(ctx context.Context, tgID int64, b *bot.Bot) (err error) {
+	ex, err := bd.GetUserExclusions(tgID)
+	if err != nil {
+		return
+	}
+	employers, err := bd.GetBlockedEmployers(tgID)
+	if err != nil {
+		return
+	}
+
+	words := "нет"
+	if len(ex.Words) != 0 {
+		words = html.EscapeString(strings.Join(ex.Words, ", "))
+	}
+	text := fmt.Sprintf("<b> <u>Исключения</u> </b>\n\n<b>Стоп-слова: </b><i> %s</i>\n<b>Скрытые работодатели: </b> %d", words, len(employers))
+
+	buttonsData := [][2]string{{"изменить стоп-слова", "#excludeWords"}}
+	if len(employers) != 0 {
+		text += "\n\nНажмите на работодателя, чтобы снова получать его вакансии."
+	}
+	for _, e := range employers {
+		buttonsData = append(buttonsData, [2]string{"✖ " + e.Company, fmt.Sprintf("?unblockEmp:%d", e.ID)})
+	}
+	buttonsData = append(buttonsData, [2]string{"« все поиски", "#mySubs"})
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: linesButtonGenerate(buttonsData)},
+	})
+	if err != nil {
+		err = fmt.Errorf("exclusions show error: %w", err)
+		return
+	}
+	return nil
+}
+
+func (s Subscription) salaryText() (text string) {
+	if s.SalaryMin <= 0 {
+		text = "не важна"
+	} else {
+		text = fmt.Sprintf("от %d %s", s.SalaryMin, s.SalaryCurrency)
+		if s.SalaryGross {
+			text += " до вычета НДФЛ"
+		} else {
+			text += " на руки"
+		}
+	}
+	if s.OnlyWithSalary {
+		text += ", только с указанной ЗП"
+	}
+	return
+}
+
+func (s Subscription) activityMark() string {
+	if s.Active {
+		return "🟢"
+	}
+	return "⚪️"
+}
+
+// Job Announce info to client of telegramBot sent
+// Отправка карточки вакансии, возвращает ИД сообщения
+func (ja JobAnnounce) sentJobAnnounceToClient(ctx context.Context, tgID int64, b *bot.Bot) (messageID int, err error) {
+	msg, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      tgID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        ja.cardText(),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: ja.linkButtons()},
+	})
+	if err != nil {
+		err = fmt.Errorf("sentJobAnnounceTo client error: %w", err)
+		return
+	}
+	return msg.ID, nil
+}
+
+// Текст карточки вакансии
+func (ja JobAnnounce) cardText() string {
+	var text string
+	if ja.SubscriptionName != "" {
+		text = "🔎 <i>" + html.EscapeString(ja.SubscriptionName) + "</i>\n"
+	}
+	if ja.Score != 0 {
+		text += fmt.Sprintf("⭐ <b>%d/100</b>", ja.Score)
+		if len(ja.ScoreReasons) != 0 {
+			text += " <i>" + html.EscapeString(strings.Join(ja.ScoreReasons, ", ")) + "</i>"
+		}
+		text += "\n"
+	}
+	text += fmt.Sprintf("<b> <u>%s</u> </b>\n<i>Наниматель: </i><b>%s</b>\n<i>Локация: </i><u>%s</u>\n\n<b>Требуемый опыт: </b><i> %s</i>\n<b>Зп \"грязными\"? -  </b>%t\n<b>Размер ЗП: </b>%.2f - %.2f%s", html.EscapeString(ja.Name), html.EscapeString(ja.Company), ja.Area, ja.Experience, ja.SalaryGross, ja.SalaryFrom, ja.SalaryTo, ja.SalaryCurrency)
+	if ja.SalaryConvCurrency != "" {
+		text += fmt.Sprintf(" <i>(≈ %.0f - %.0f%s)</i>", ja.SalaryConvFrom, ja.SalaryConvTo, ja.SalaryConvCurrency)
+	}
+	text += "\n<b>График работы: </b>" + ja.Schedule
+
+	if ja.Employment != "" {
+		text += "\n<b>Занятость: </b>" + html.EscapeString(ja.Employment)
+	}
+	if ja.Address != "" {
+		text += "\n<b>Адрес: </b>" + html.EscapeString(ja.Address)
+	}
+	if ja.KeySkills != "" {
+		text += "\n<b>Навыки: </b><i>" + html.EscapeString(ja.KeySkills) + "</i>"
+	}
+	if ja.Description != "" {
+		text += "\n\n" + html.EscapeString(vacsource.Cut(ja.Description, descriptionCardLength))
+	}
+	return text
+}
+
+// Кнопки-ссылки на вакансию: основной источник и все найденные дубли, затем действия с карточкой.
+// Вакансии лент бывают без ссылки - кнопка с пустым URL не отправится
+func (ja JobAnnounce) linkButtons() (buttons [][]models.InlineKeyboardButton) {
+	if len(ja.DuplicateLinks) == 0 {
+		if ja.Link != "" {
+			buttons = append(buttons, []models.InlineKeyboardButton{{Text: "источник", URL: ja.Link}})
+		}
+	} else {
+		if ja.Link != "" {
+			buttons = append(buttons, []models.InlineKeyboardButton{{Text: "источник: " + ja.Source, URL: ja.Link}})
+		}
+		for _, link := range ja.DuplicateLinks {
+			if link[1] != "" {
+				buttons = append(buttons, []models.InlineKeyboardButton{{Text: "источник: " + link[0], URL: link[1]}})
+			}
+		}
+	}
+
+	actions := []models.InlineKeyboardButton{{Text: "⭐ в избранное", CallbackData: fmt.Sprintf("?save:%d", ja.ItemID)}}
+	if ja.Company != "" {
+		actions = append(actions, models.InlineKeyboardButton{Text: "скрыть работодателя", CallbackData: fmt.Sprintf("?hideEmp:%d", ja.ItemID)})
+	}
+	buttons = append(buttons, actions)
 	return
 }
 
-// User data of search, from model of package bd to telebot model convert
-func convertUserModelDBtoTG(sqluser bd.UserData) (ud UserData) {
-	ud = UserData{TgID: sqluser.TgID, Vacancy: sqluser.VacancyName, ExperienceYears: sqluser.ExperienceYear}
+// ------------------------------------->>>MODEL CONVERTERS-----------------------------------
+// Subscription, from model of package bd to telebot model convert
+func convertSubscriptionModelDBtoTG(sqls bd.Subscription) (s Subscription) {
+	s = Subscription{ID: sqls.ID, Name: sqls.Name, Active: sqls.Active, Vacancy: sqls.VacancyName, ExperienceYears: sqls.ExperienceYear, SalaryMin: sqls.SalaryMin, SalaryCurrency: sqls.SalaryCurrency, SalaryGross: sqls.SalaryGross, OnlyWithSalary: sqls.OnlyWithSalary}
 
-	if sqluser.Location == 0 {
-		ud.Location = "не имеет значения"
+	if sqls.Location == 0 {
+		s.Location = "не имеет значения"
 	} else {
-		loc, err := bd.FindLocByID(sqluser.Location)
+		loc, err := bd.FindLocByID(sqls.Location)
 		if err != nil {
 			logger.Error(err.Error())
-			ud.Location = "не имеет значения"
+			s.Location = "не имеет значения"
 		} else {
-			ud.Location = loc
+			s.Location = loc
 		}
 	}
 
-	res, _ := bd.GetSchedule(sqluser.Schedule)
-	ud.Schedule = res[0].Name
+	if res, _ := bd.GetSchedule(sqls.Schedule); len(res) != 0 {
+		s.Schedule = res[0].Name
+	}
 
-	if sqluser.VacancyName == "" {
-		ud.Vacancy = "не указано"
+	if sqls.VacancyName == "" {
+		s.Vacancy = "не указано"
 	}
 	return
 }
@@ -169,16 +406,53 @@ func convertJobDataModelDBtoTG(dbData []bd.JobAnnounce, areas bd.Countries) (ja
 			}
 		}
 
-		ja = append(ja, JobAnnounce{ItemID: uint(dd.ItemId), Name: dd.Name, Company: dd.Company, Area: fmt.Sprintf("%s %s %s", coName, rName, ciName), Experience: dd.Expierence, SalaryGross: dd.SalaryGross, SalaryFrom: dd.SalaryFrom, SalaryTo: dd.SalaryTo, SalaryCurrency: dd.SalaryCurrency, Schedule: schedule, Link: dd.Link})
+		ja = append(ja, JobAnnounce{ItemID: uint(dd.ItemId), Name: dd.Name, Company: dd.Company, Area: fmt.Sprintf("%s %s %s", coName, rName, ciName), Experience: dd.Expierence, SalaryGross: dd.SalaryGross, SalaryFrom: dd.SalaryFrom, SalaryTo: dd.SalaryTo, SalaryCurrency: dd.SalaryCurrency, Schedule: schedule, Link: dd.Link, Source: dd.Source, Employment: dd.Employment, Address: dd.Address, KeySkills: dd.KeySkills, Description: dd.Description})
 	}
 	return
 
 }
 
-// Job announce slice data model of packcage hh to slice model JobAnnounce convert
-func convertAnnounceHHtoTG(hhja hh.HHresponse) (ja []JobAnnounce) {
-	for _, ha := range hhja.Items {
-		ja = append(ja, JobAnnounce{Name: ha.Name, Company: ha.Employer.Name, Area: ha.Area.Name, Experience: ha.Experience.Name, SalaryGross: ha.Salary.Gross, SalaryFrom: ha.Salary.From, SalaryTo: ha.Salary.To, SalaryCurrency: ha.Salary.Currency, PublishedAt: ha.PublishedAt, Schedule: ha.Schedule.Name, Requirement: ha.Snippet.Requirement, Responsebility: ha.Snippet.Responsibility, Link: ha.PageURL})
+// Пересчет зарплат вакансий в валюту пользователя по справочнику валют
+func convertSalaries(ja []JobAnnounce, currency string) []JobAnnounce {
+	cur, err := bd.GetCurrencies()
+	if err != nil {
+		logger.Error(err.Error())
+		return ja
 	}
-	return
+
+	for i, a := range ja {
+		if a.SalaryCurrency == "" || a.SalaryCurrency == currency || (a.SalaryFrom == 0 && a.SalaryTo == 0) {
+			continue
+		}
+		from, ok := cur.Convert(a.SalaryFrom, a.SalaryCurrency, currency)
+		if !ok {
+			continue
+		}
+		to, _ := cur.Convert(a.SalaryTo, a.SalaryCurrency, currency)
+		ja[i].SalaryConvFrom, ja[i].SalaryConvTo, ja[i].SalaryConvCurrency = from, to, currency
+	}
+	return ja
+}
+
+// Ссылки дублей вакансий из других источников
+func attachDuplicateLinks(ja []JobAnnounce) []JobAnnounce {
+	ids := make([]uint, 0, len(ja))
+	for _, a := range ja {
+		ids = append(ids, a.ItemID)
+	}
+
+	duplicates, err := bd.GetDuplicates(ids)
+	if err != nil {
+		logger.Error(err.Error())
+		return ja
+	}
+
+	for i, a := range ja {
+		for _, d := range duplicates[a.ItemID] {
+			if d.Link != "" && d.Link != a.Link {
+				ja[i].DuplicateLinks = append(ja[i].DuplicateLinks, [2]string{d.Source, d.Link})
+			}
+		}
+	}
+	return ja
 }
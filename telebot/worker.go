@@ -2,16 +2,39 @@ package telebot
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 	"vacancydealer/bd"
 	"vacancydealer/logger"
+	"vacancydealer/vacsource"
 
 	"github.com/go-telegram/bot"
 )
 
+// Сколько лучших по оценке вакансий подписки отправляется за цикл
+const deliveryTopN = 10
+
+// Пользователь заблокировал бота: дальнейшие отправки бесполезны
+func botBlocked(err error) bool {
+	return errors.Is(err, bot.ErrorForbidden)
+}
+
+// Рассылка заблокировавшему бота приостанавливается, возобновить - /resume
+func pauseBlockedUser(u bd.UserData) {
+	if err := u.SetActive(false); err != nil {
+		logger.Error(err.Error())
+		return
+	}
+	logger.Info(fmt.Sprintf("user %d blocked the bot, delivery paused", u.TgID))
+}
+
 // Automatic worker
 // New vacancieAnnounces to user sent
+// При отмене ctx рассылка текущему пользователю дорабатывает до конца, затем воркер завершается
 func StartWorker(ctx context.Context, b *bot.Bot) {
+	sendCtx := context.WithoutCancel(ctx)
+
 	areas, err := bd.CountriesLis()
 	if err != nil {
 		logger.Error(err.Error())
@@ -19,40 +42,110 @@ func StartWorker(ctx context.Context, b *bot.Bot) {
 	}
 
 	for {
-		uds, err := bd.GetAllUserData()
+		subs, err := bd.GetActiveSubscriptions(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		users, err := bd.GetAllUserData(ctx)
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
+		userSubs := make(map[int64]bd.Subscriptions, len(users))
+		for _, s := range subs {
+			userSubs[s.TgID] = append(userSubs[s.TgID], s)
+		}
+		usersByID := make(map[int64]bd.UserData, len(users))
+		locations := make(map[int64]*time.Location, len(users))
+		for _, u := range users {
+			usersByID[u.TgID] = u
+			locations[u.TgID] = u.TimeLocation(userSubs[u.TgID], areas)
+		}
+		// время по часовому поясу пользователя
+		localNow := func(tgID int64) time.Time {
+			if loc, ok := locations[tgID]; ok {
+				return time.Now().In(loc)
+			}
+			return time.Now()
+		}
+
+		// заблокировавшие бота в этом цикле, их остальные подписки пропускаются
+		blocked := make(map[int64]bool)
 
-		for _, ud := range uds {
-			a, err := ud.GetJobAnnounces(areas)
+		// показанные вакансии пишутся сразу после каждой подписки,
+		// поэтому следующая подписка того же пользователя их уже не получит
+		for _, s := range subs {
+			if ctx.Err() != nil {
+				return
+			}
+			if blocked[s.TgID] {
+				continue
+			}
+
+			a, err := s.GetJobAnnounces(ctx, areas)
+			if err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			cur, err := bd.GetCurrencies()
 			if err != nil {
 				logger.Error(err.Error())
 				continue
 			}
 
-			var showedJobAnnoucesIDs []uint
+			// доставляются только лучшие - остальные придут в следующих циклах
+			ranked := s.Rank(a, areas, cur, deliveryTopN, time.Now())
+			top := make(bd.JobAnnounces, 0, len(ranked))
+			for _, r := range ranked {
+				top = append(top, r.JobAnnounce)
+			}
+
+			// неудачные отправки тоже отмечаются: такие вакансии будут предложены снова
+			var deliveries []bd.UserPivotVacancy
 
-			for _, ja := range convertJobDataModelDBtoTG(a, areas) {
-				if err = ja.sentJobAnnounceToClient(ctx, ud.TgID, b); err != nil {
+			// в режиме дайджеста и в тихие часы вакансии копятся до отправки
+			if u := usersByID[s.TgID]; u.DeliveryMode == bd.DeliveryModeHourly || u.DeliveryMode == bd.DeliveryModeDaily || u.InQuietHours(localNow(s.TgID)) {
+				for _, r := range ranked {
+					deliveries = append(deliveries, bd.UserPivotVacancy{JobID: r.ItemId, Status: bd.DeliveryQueued, Score: r.Score.Score})
+				}
+				if err = bd.SaveDeliveries(sendCtx, uint(s.TgID), deliveries); err != nil {
 					logger.Error(err.Error())
-					continue
 				}
-
-				showedJobAnnoucesIDs = append(showedJobAnnoucesIDs, ja.ItemID)
+				continue
 			}
 
-			if len(showedJobAnnoucesIDs) != 0 {
-				if err = bd.CreatePivotVacancyAnnouncesAndUserIds(showedJobAnnoucesIDs, uint(ud.TgID)); err != nil {
+			for i, ja := range convertSalaries(attachDuplicateLinks(convertJobDataModelDBtoTG(top, areas)), s.SalaryCurrency) {
+				ja.SubscriptionName = s.Name
+				ja.Score, ja.ScoreReasons = ranked[i].Score.Score, ranked[i].Score.Reasons
+
+				d := bd.UserPivotVacancy{JobID: ja.ItemID, Status: bd.DeliverySent, SentAt: time.Now(), Score: ja.Score}
+				if d.MessageID, err = ja.sentJobAnnounceToClient(sendCtx, s.TgID, b); err != nil {
 					logger.Error(err.Error())
-					continue
+					d.Status = bd.DeliveryFailed
+				}
+				deliveries = append(deliveries, d)
+
+				if botBlocked(err) {
+					pauseBlockedUser(bd.UserData{TgID: s.TgID})
+					blocked[s.TgID] = true
+					break
 				}
 			}
+
+			if err = bd.SaveDeliveries(sendCtx, uint(s.TgID), deliveries); err != nil {
+				logger.Error(err.Error())
+			}
 		}
 
-		if len(uds) != 0 {
-			time.Sleep(time.Duration(1530/len(uds)) * time.Second) //period
+		sendDigests(sendCtx, b, users, localNow)
+
+		period := time.Minute // пока подписок нет
+		if len(subs) != 0 {
+			period = time.Duration(1530/len(subs)) * time.Second
+		}
+		if !vacsource.Pause(ctx, period) {
+			return
 		}
 
 	}
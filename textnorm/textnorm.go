@@ -0,0 +1,178 @@
+// Нормализация текста вакансий и запросов для сопоставления по словам.
+//
+// Текст режется на слова по границам, слова приводятся к нижнему регистру, ё заменяется на е,
+// русские и английские окончания отбрасываются, а русские написания технологий
+// ("джава", "питон", "бэкенд") заменяются английскими.
+// Нормализованный текст обрамлен пробелами: совпадение по словам - LIKE '% слово %'
+package textnorm
+
+import (
+	"strings"
+	"unicode"
+)
+
+const minStemLength = 3 // короче основа не обрезается, в рунах
+
+var (
+	// окончания от длинных к коротким, снимается первое подошедшее
+	ruEndings = []string{
+		"иями", "ями", "ами", "ией", "иям", "ием", "иях", "ого", "его", "ому", "ему", "ыми", "ими",
+		"ая", "яя", "ое", "ее", "ые", "ие", "ый", "ий", "ой", "ей", "ом", "ем", "ам", "ям", "ах", "ях", "ую", "юю", "ов", "ев", "ью", "ия", "ья",
+		"а", "я", "ы", "и", "у", "ю", "е", "о", "ь", "й",
+	}
+	enSuffixes = []string{"ment", "ing", "ed", "er"}
+
+	// русские написания технологий и грейдов
+	translit = map[string]string{
+		"джава":       "java",
+		"ява":         "java",
+		"джаваскрипт": "javascript",
+		"яваскрипт":   "javascript",
+		"тайпскрипт":  "typescript",
+		"голанг":      "golang",
+		"питон":       "python",
+		"пайтон":      "python",
+		"пхп":         "php",
+		"руби":        "ruby",
+		"котлин":      "kotlin",
+		"свифт":       "swift",
+		"сишарп":      "c#",
+		"реакт":       "react",
+		"ангуляр":     "angular",
+		"ларавель":    "laravel",
+		"джанго":      "django",
+		"линукс":      "linux",
+		"докер":       "docker",
+		"кубернетес":  "kubernetes",
+		"постгрес":    "postgres",
+		"постгрескл":  "postgresql",
+		"фронтенд":    "frontend",
+		"фронтэнд":    "frontend",
+		"бэкенд":      "backend",
+		"бекенд":      "backend",
+		"бэкэнд":      "backend",
+		"фулстек":     "fullstack",
+		"девопс":      "devops",
+		"тимлид":      "teamlead",
+		"джун":        "junior",
+		"джуниор":     "junior",
+		"мидл":        "middle",
+		"сеньор":      "senior",
+		"синьор":      "senior",
+	}
+	// те же написания по основе: "питона", "бэкенда"
+	translitStems = make(map[string]string, len(translit))
+)
+
+func init() {
+	for k, v := range translit {
+		translitStems[stem(k)] = v
+	}
+}
+
+// Слова текста: буквы и цифры, а также "+", "#" после слова (c++, c#)
+// и точка внутри или в начале слова (node.js, .net)
+func Tokenize(text string) (tokens []string) {
+	runes := []rune(text)
+	var current []rune
+	flush := func() {
+		if len(current) != 0 {
+			tokens = append(tokens, string(current))
+			current = current[:0]
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current = append(current, r)
+		case (r == '+' || r == '#') && len(current) != 0:
+			current = append(current, r)
+		case r == '.' && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) && (len(current) != 0 || i == 0 || unicode.IsSpace(runes[i-1])):
+			current = append(current, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return
+}
+
+// Нормальная форма одного слова
+func Word(word string) string {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	if t, ok := translit[word]; ok {
+		word = t
+	} else if t, ok := translitStems[stem(word)]; ok {
+		word = t
+	}
+	return stem(word)
+}
+
+// Нормализованный текст: нормальные формы слов через пробел, с пробелами по краям.
+// Пустой текст - пустая строка
+func Normalize(text string) string {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+	for i, t := range tokens {
+		tokens[i] = Word(t)
+	}
+	return " " + strings.Join(tokens, " ") + " "
+}
+
+// Отбрасывание окончания. Слова с цифрами и символами не трогаются
+func stem(word string) string {
+	var cyrillic, latin bool
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic = true
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin = true
+		default:
+			return word
+		}
+	}
+
+	switch {
+	case cyrillic && !latin:
+		return stemRussian(word)
+	case latin && !cyrillic:
+		return stemEnglish(word)
+	}
+	return word
+}
+
+func stemRussian(word string) string {
+	length := len([]rune(word))
+	for _, e := range ruEndings {
+		if strings.HasSuffix(word, e) && length-len([]rune(e)) >= minStemLength {
+			return strings.TrimSuffix(word, e)
+		}
+	}
+	return word
+}
+
+func stemEnglish(word string) string {
+	// множественное число
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word)-3 >= minStemLength:
+		word = strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is") && len(word)-1 >= minStemLength:
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	for _, s := range enSuffixes {
+		if strings.HasSuffix(word, s) && len(word)-len(s) >= minStemLength {
+			word = strings.TrimSuffix(word, s)
+			break
+		}
+	}
+
+	if strings.HasSuffix(word, "e") && len(word)-1 >= minStemLength {
+		word = strings.TrimSuffix(word, "e")
+	}
+	return word
+}
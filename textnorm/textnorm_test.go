@@ -0,0 +1,63 @@
+package textnorm_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"vacancydealer/textnorm"
+)
+
+func TestTokenize(t *testing.T) {
+	cases := map[string][]string{
+		"Golang-разработчик (Senior)": {"Golang", "разработчик", "Senior"},
+		"C++/C# developer":            {"C++", "C#", "developer"},
+		"Node.js, .NET и 1С.":         {"Node.js", ".NET", "и", "1С"},
+		"  ":                          nil,
+	}
+	for text, expected := range cases {
+		if got := textnorm.Tokenize(text); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Tokenize(%q) = %q, expected %q", text, got, expected)
+		}
+	}
+}
+
+func TestWordForms(t *testing.T) {
+	same := [][]string{
+		{"разработчик", "разработчика", "разработчиков", "Разработчики"},
+		{"ведущий", "ведущего", "ведущему"},
+		{"ёлка", "елка"},
+		{"developer", "developers", "developing", "development"},
+		{"service", "services"},
+		{"java", "Джава", "джавы"},
+		{"python", "питон", "питона", "Пайтон"},
+		{"backend", "бэкенд", "бекенда"},
+	}
+	for _, forms := range same {
+		expected := textnorm.Word(forms[0])
+		for _, f := range forms[1:] {
+			if got := textnorm.Word(f); got != expected {
+				t.Errorf("Word(%q) = %q, expected %q as for %q", f, got, expected, forms[0])
+			}
+		}
+	}
+}
+
+func TestNormalizeWordBoundaries(t *testing.T) {
+	cases := []struct {
+		text, query string
+		match       bool
+	}{
+		{"Logo designer", "go", false},
+		{"Go developer", "go", true},
+		{"Ведущий разработчика Golang", "разработчик golang", true},
+		{"Старший бэкенд-разработчик", "backend", true},
+		{"Программист 1С", "1с", true},
+		{"Java developer", "javascript", false},
+	}
+	for _, c := range cases {
+		got := strings.Contains(textnorm.Normalize(c.text), textnorm.Normalize(c.query))
+		if got != c.match {
+			t.Errorf("Normalize(%q) contains Normalize(%q) = %t, expected %t", c.text, c.query, got, c.match)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package vacsource
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlBreakRe  = regexp.MustCompile(`(?i)<(br|/p|/li|/div|/h[1-6])\s*/?>`)
+	htmlTagRe    = regexp.MustCompile(`<[^>]*>`)
+	whitespaceRe = regexp.MustCompile(`[ \t\r\f\v]+`)
+	newlinesRe   = regexp.MustCompile(`\s*\n\s*`)
+)
+
+// Текст без html-разметки: абзацы и пункты списков - отдельными строками, пробелы схлопнуты
+func PlainText(markup string) string {
+	text := htmlBreakRe.ReplaceAllString(html.UnescapeString(markup), "\n")
+	text = html.UnescapeString(htmlTagRe.ReplaceAllString(text, " "))
+	text = whitespaceRe.ReplaceAllString(text, " ")
+	return strings.TrimSpace(newlinesRe.ReplaceAllString(text, "\n"))
+}
+
+// Начало текста не длиннее limit рун
+func Cut(text string, limit int) string {
+	if runes := []rune(text); len(runes) > limit {
+		return strings.TrimSpace(string(runes[:limit])) + "…"
+	}
+	return text
+}
@@ -0,0 +1,96 @@
+package vacsource
+
+import (
+	"context"
+	"errors"
+	"time"
+	"vacancydealer/bd"
+)
+
+type (
+	// Источник вакансий (доска объявлений).
+	// ИД вакансий в bd.JobAnnounce должны быть уникальны между источниками: см. bd.ItemIDFor
+	VacancySource interface {
+		// Имя источника, пишется в bd.JobAnnounce.Source
+		Name() string
+		// Загрузка справочников источника (локации, графики работы) в БД
+		LoadDictionaries(ctx context.Context) error
+		// Живой поиск по фильтру пользователя: одна страница выдачи
+		Search(ctx context.Context, filter Filter, perPage, page int) (Page, error)
+		// Выгрузка всех вакансий по шаблону названия, опубликованных не раньше dateFrom.
+		// Нулевая dateFrom - полная выгрузка
+		Harvest(ctx context.Context, pattern string, dateFrom time.Time, areas bd.Countries) (bd.JobAnnounces, error)
+		// Вакансия целиком по ИД источника, с заполненными bd.VacancyDetails.
+		// Удаленная с источника вакансия - ErrVacancyNotFound
+		VacancyDetails(ctx context.Context, sourceID string) (bd.JobAnnounce, error)
+	}
+
+	// Фильтр пользователя, общий для всех источников
+	Filter struct {
+		TgID           int64
+		VacancyName    string
+		ExperienceYear int
+		Schedule       string
+		Location       uint
+		SalaryMin      int
+		SalaryCurrency string
+		SalaryGross    bool
+		OnlyWithSalary bool
+		Exclusions     bd.Exclusions
+	}
+
+	// Страница выдачи живого поиска
+	Page struct {
+		Found int
+		Pages int
+		Items bd.JobAnnounces
+	}
+)
+
+var (
+	sources []VacancySource
+
+	ErrVacancyNotFound = errors.New("vacancy not found")
+)
+
+// Регистрация источника. Вызывается при старте, до запуска воркеров
+func Register(src VacancySource) {
+	sources = append(sources, src)
+}
+
+// Зарегистрированные источники
+func List() []VacancySource {
+	return sources
+}
+
+// Источник по имени
+func Find(name string) (VacancySource, bool) {
+	for _, src := range sources {
+		if src.Name() == name {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+func names() (list []string) {
+	for _, src := range sources {
+		list = append(list, src.Name())
+	}
+	return
+}
+
+// subscription model of package bd to Filter convert
+func FilterFromSubscription(s bd.Subscription, ex bd.Exclusions) Filter {
+	return Filter{TgID: s.TgID, VacancyName: s.VacancyName, ExperienceYear: s.ExperienceYear, Schedule: s.Schedule, Location: s.Location, SalaryMin: s.SalaryMin, SalaryCurrency: s.SalaryCurrency, SalaryGross: s.SalaryGross, OnlyWithSalary: s.OnlyWithSalary, Exclusions: ex}
+}
+
+// Отсев выдачи по стоп-словам и скрытым работодателям пользователя
+func (f Filter) Exclude(items bd.JobAnnounces) (kept bd.JobAnnounces) {
+	for _, a := range items {
+		if !f.Exclusions.Excludes(a) {
+			kept = append(kept, a)
+		}
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package vacsource
+
+import (
+	"context"
+	"errors"
+	"time"
+	"vacancydealer/bd"
+	"vacancydealer/logger"
+)
+
+const (
+	watermarkOverlap = 30 * time.Minute // запас на задержку индексации вакансий в поиске источника
+	detailsBatchSize = 100
+	detailsIdlePause = time.Minute
+	savedBatchSize   = 100
+	savedIdlePause   = 10 * time.Minute
+)
+
+// vacancy announces harvester
+// Каждый шаблон выгружается из каждого источника инкрементально - только вакансии новее отметки последней выгрузки.
+// Полная выгрузка - при первом запуске и если с прошлой полной прошло больше resyncGap
+func WorkerStart(ctx context.Context, pauseDuration int, resyncGap time.Duration) {
+	if !Pause(ctx, time.Duration(10)*time.Second) {
+		return
+	}
+
+	areas, err := bd.CountriesLis()
+	if err != nil {
+		logger.Error(err.Error())
+		panic(err)
+	}
+
+	for {
+		keys, err := bd.GetVacancyPatterns(ctx)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		for _, k := range keys {
+			for _, src := range sources {
+				if err = harvest(ctx, src, k.VacancyName, areas, resyncGap); err != nil {
+					logger.Error(err.Error())
+				}
+			}
+
+			if !Pause(ctx, time.Duration(pauseDuration/len(keys))*time.Second) {
+				return
+			}
+		}
+
+		if !Pause(ctx, time.Duration(pauseDuration)*time.Second) {
+			return
+		}
+	}
+}
+
+// Выгрузка одного шаблона из одного источника
+func harvest(ctx context.Context, src VacancySource, pattern string, areas bd.Countries, resyncGap time.Duration) (err error) {
+	if pattern == "" {
+		return nil
+	}
+
+	mark, err := bd.GetHarvestWatermark(ctx, src.Name(), pattern)
+	if err != nil {
+		return
+	}
+
+	var dateFrom time.Time
+	fullSync := mark.LastFullSyncAt.IsZero() || time.Since(mark.LastFullSyncAt) > resyncGap
+	// полная выгрузка могла ничего не найти - тогда отметки нет и выгрузка снова без даты
+	if !fullSync && !mark.LastPublishedAt.IsZero() {
+		dateFrom = mark.LastPublishedAt.Add(-watermarkOverlap)
+	}
+
+	syncStarted := time.Now()
+	items, err := src.Harvest(ctx, pattern, dateFrom, areas)
+	if err != nil {
+		return
+	}
+	if items, err = items.Deduplicate(ctx); err != nil {
+		return
+	}
+	if err = items.SaveInDB(ctx); err != nil {
+		return
+	}
+
+	if latest := items.LatestPublishedAt(); latest.After(mark.LastPublishedAt) {
+		mark.LastPublishedAt = latest
+	}
+	mark.LastSyncAt = syncStarted
+	if fullSync {
+		mark.LastFullSyncAt = syncStarted
+	}
+	return mark.SaveInDB()
+}
+
+// vacancy details fetcher
+// Очередь - вакансии без загруженной карточки в БД. Запросы к источникам идут не чаще одного за interval
+func DetailsWorkerStart(ctx context.Context, interval time.Duration) {
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+
+	for {
+		queue, err := bd.GetDetailsQueue(ctx, names(), detailsBatchSize)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		if len(queue) == 0 {
+			if !Pause(ctx, detailsIdlePause) {
+				return
+			}
+			continue
+		}
+
+		for _, a := range queue {
+			select {
+			case <-ctx.Done():
+				return
+			case <-limiter.C:
+			}
+			if err = fetchDetails(ctx, a); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+func fetchDetails(ctx context.Context, a bd.JobAnnounce) (err error) {
+	src, ok := Find(a.Source)
+	if !ok {
+		return a.DetailsAttemptFailed()
+	}
+
+	details, err := src.VacancyDetails(ctx, a.SourceID)
+	if errors.Is(err, ErrVacancyNotFound) {
+		a.Archived = true
+		return a.SaveDetails()
+	}
+	if err != nil {
+		if ctx.Err() != nil { // остановка сервиса - не попытка
+			return
+		}
+		if attemptErr := a.DetailsAttemptFailed(); attemptErr != nil {
+			logger.Error(attemptErr.Error())
+		}
+		return
+	}
+
+	a.VacancyDetails = details.VacancyDetails
+	return a.SaveDetails()
+}
+
+// saved vacancies checker
+// Вакансии из избранного перепроверяются на источнике не чаще раза в recheck:
+// ушедшие в архив и удаленные помечаются и больше не проверяются. Запросы не чаще одного за interval
+func SavedCheckerStart(ctx context.Context, interval, recheck time.Duration) {
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+
+	for {
+		queue, err := bd.GetSavedCheckQueue(ctx, names(), time.Now().Add(-recheck), savedBatchSize)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		if len(queue) == 0 {
+			if !Pause(ctx, savedIdlePause) {
+				return
+			}
+			continue
+		}
+
+		for _, a := range queue {
+			select {
+			case <-ctx.Done():
+				return
+			case <-limiter.C:
+			}
+			if err = checkSaved(ctx, a); err != nil {
+				logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+func checkSaved(ctx context.Context, a bd.JobAnnounce) (err error) {
+	src, ok := Find(a.Source)
+	if !ok {
+		return
+	}
+
+	status := bd.SavedActive
+	details, err := src.VacancyDetails(ctx, a.SourceID)
+	switch {
+	case errors.Is(err, ErrVacancyNotFound):
+		status = bd.SavedRemoved
+	case err != nil:
+		if ctx.Err() != nil { // остановка сервиса - не проверка
+			return
+		}
+		// ошибка источника - повтор через recheck, а не в следующей пачке
+		logger.Error(err.Error())
+	case details.Archived:
+		status = bd.SavedArchived
+	}
+	return bd.MarkSavedChecked(ctx, a.ItemId, status, time.Now())
+}
+
+// Пауза воркера. false - контекст отменен, воркеру пора завершаться
+func Pause(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}